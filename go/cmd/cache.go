@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser/cache"
+)
+
+func runCache(args []string) int {
+	if len(args) == 0 || (args[0] != "ls" && args[0] != "clear") {
+		printError("SUBCOMMAND_REQUIRED", "Expected a cache subcommand: ls or clear", nil)
+		fmt.Fprintln(os.Stderr, "\nUsage: qcparser cache ls|clear [--cache-dir=DIR]")
+		return ExitInvalidArgs
+	}
+	subcommand := args[0]
+
+	// Create flag set for cache command
+	fs := flag.NewFlagSet("cache "+subcommand, flag.ExitOnError)
+
+	// Define flags
+	cacheDir := fs.String("cache-dir", cache.DefaultDir(), "Directory of cached conversions")
+
+	// Parse flags
+	fs.Parse(args[1:])
+
+	var output interface{}
+	if subcommand == "ls" {
+		entries, err := cache.List(*cacheDir)
+		if err != nil {
+			printError("CACHE_FAILED", err.Error(), map[string]interface{}{
+				"cache_dir": *cacheDir,
+			})
+			return ExitGeneralError
+		}
+
+		var total int64
+		for _, entry := range entries {
+			total += entry.Bytes
+		}
+		output = map[string]interface{}{
+			"cache_dir":   *cacheDir,
+			"entries":     entries,
+			"total_bytes": total,
+		}
+	} else {
+		removed, freed, err := cache.Clear(*cacheDir)
+		if err != nil {
+			printError("CACHE_FAILED", err.Error(), map[string]interface{}{
+				"cache_dir": *cacheDir,
+			})
+			return ExitGeneralError
+		}
+		output = map[string]interface{}{
+			"cache_dir":   *cacheDir,
+			"removed":     removed,
+			"bytes_freed": freed,
+		}
+	}
+
+	// Marshal result to JSON
+	data, err := json.Marshal(output)
+	if err != nil {
+		printError("JSON_MARSHAL_ERROR", err.Error(), nil)
+		return ExitGeneralError
+	}
+
+	fmt.Println(string(data))
+
+	return ExitSuccess
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser/types"
+)
+
+// cliConfig is the JSON document accepted by --config.
+// Fields left out keep their default values.
+type cliConfig struct {
+	Heuristics *types.HeuristicConfig `json:"heuristics"`
+}
+
+// loadConfig applies a config file to opts
+func loadConfig(path string, opts *types.Options) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cfg := cliConfig{Heuristics: &opts.Heuristics}
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	return validateHeuristics(opts.Heuristics.Apply(types.DefaultHeuristics()))
+}
+
+// validateHeuristics checks that weights and thresholds are in range
+func validateHeuristics(h types.Heuristics) error {
+	weights := map[string]float64{
+		"coverage_weight": h.CoverageWeight,
+		"spread_weight":   h.SpreadWeight,
+		"invalid_weight":  h.InvalidWeight,
+		"quote_weight":    h.QuoteWeight,
+	}
+	for name, w := range weights {
+		if w < 0 {
+			return fmt.Errorf("heuristics.%s must not be negative", name)
+		}
+	}
+
+	rates := map[string]float64{
+		"min_mode_coverage":  h.MinModeCoverage,
+		"max_invalid_rate":   h.MaxInvalidRate,
+		"min_pii_share":      h.MinPIIShare,
+		"min_semantic_share": h.MinSemanticShare,
+	}
+	for name, r := range rates {
+		if r < 0 || r > 1 {
+			return fmt.Errorf("heuristics.%s must be between 0 and 1", name)
+		}
+	}
+
+	if h.AmbiguityEpsilon < 0 {
+		return fmt.Errorf("heuristics.ambiguity_epsilon must not be negative")
+	}
+	return nil
+}
+
+// applyConfig loads the --config file when one is given and reports failures
+func applyConfig(path string, opts *types.Options) int {
+	if path == "" {
+		return ExitSuccess
+	}
+	if err := loadConfig(path, opts); err != nil {
+		printError("CONFIG_INVALID", err.Error(), map[string]interface{}{
+			"config": path,
+		})
+		return ExitInvalidArgs
+	}
+	return ExitSuccess
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"querycraft/pkg/qcparser"
+	"querycraft/pkg/qcparser/cache"
+	"querycraft/pkg/qcparser/detector"
 	"querycraft/pkg/qcparser/types"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -16,14 +26,45 @@ func runConvert(args []string) int {
 	fs := flag.NewFlagSet("convert", flag.ExitOnError)
 
 	// Define flags
-	inputPath := fs.String("input", "", "Input file path (required)")
-	outputPath := fs.String("output", "", "Output DJSON file path (required)")
+	var inputs inputList
+	fs.Var(&inputs, "input", "Input file path or glob, or - for stdin; repeat for several inputs (required)")
+	outputPath := fs.String("output", "", "Output DJSON file path, or - for stdout (required)")
+	skipRows := fs.Int("skip-rows", -1, "Number of preamble lines to skip (default: auto-detect)")
+	raggedRows := fs.String("ragged-rows", types.RaggedReject, "Ragged row policy: reject, pad, truncate or extra")
+	sampling := fs.String("sampling", types.SampleHead, "Detection sampling strategy: head, stratified or reservoir")
+	locale := fs.String("locale", "", "Locale of numbers and month names: en, de, fr, es, it, pt or nl (default: detected per column)")
+	configPath := fs.String("config", "", "JSON config file with detection heuristics")
+	schemaPath := fs.String("schema", "", "Schema JSON file to use instead of detection")
+	perInput := fs.Bool("per-input", false, "With several inputs, write one DJSON per input into the --output directory")
+	sourceColumn := fs.Bool("source-column", false, "Add a _source_file column with each row's input path")
+	selectColumns := fs.String("select", "", "Comma-separated columns to write, in output order (default: all)")
+	where := fs.String("where", "", "Keep rows matching an expression, e.g. \"status IN ('open','new') AND amount > 10\"")
+	var masks maskList
+	fs.Var(&masks, "mask", "Mask a column as col:redact, col:hash, col:last:N or col:tokenize; repeat or comma-separate for several columns")
+	maskSalt := fs.String("mask-salt", os.Getenv("QCPARSER_MASK_SALT"), "Secret salt of the hash and tokenize masks (default: $QCPARSER_MASK_SALT)")
+	transformPath := fs.String("transform", "", "JSON transform spec of column operations applied before writing")
+	dedupeOn := fs.String("dedupe-on", "", "Comma-separated key columns; rows repeating an earlier key are dropped")
+	dedupeRows := fs.Bool("dedupe", false, "Drop rows repeating every column of an earlier row")
+	dedupeKeep := fs.String("dedupe-keep", types.DedupeFirst, "Row kept among duplicates: first or last")
+	sortBy := fs.String("sort-by", "", "Comma-separated columns to order rows by, each optionally suffixed :asc or :desc")
+	maxRowsPerFile := fs.Int64("max-rows-per-file", 0, "Split the output into part files of at most this many rows; --output is then a directory")
+	maxBytesPerFile := fs.Int64("max-bytes-per-file", 0, "Split the output into part files of at most this many bytes; --output is then a directory")
+	partitionBy := fs.String("partition-by", "", "Comma-separated columns writing Hive-style directories, each optionally suffixed :day, :month or :year")
+	memoryBytes := fs.Int64("memory-bytes", 256<<20, "Memory held by dedupe and sort before spilling to disk (default: 256MB)")
+	tempDir := fs.String("temp-dir", "", "Directory for spill files (default: system temp dir)")
+	follow := fs.Bool("follow", false, "Keep appending records as the input grows, like tail -F, until interrupted")
+	cacheDir := fs.String("cache-dir", "", "Reuse and store conversions in this directory, e.g. "+cache.DefaultDir()+" as listed by 'qcparser cache ls' (default: no cache)")
+	cacheMaxBytes := fs.Int64("cache-max-bytes", cache.DefaultMaxBytes, "Cache size above which the least recently used conversions are evicted (default: 10GB)")
+	pollInterval := fs.Duration("poll-interval", time.Second, "How often --follow checks the input for new records")
 
 	// Parse flags
 	fs.Parse(args)
 
+	// Inputs may also follow the flags
+	inputs = append(inputs, fs.Args()...)
+
 	// Validate required flags
-	if *inputPath == "" {
+	if len(inputs) == 0 {
 		printError("INPUT_REQUIRED", "The --input flag is required", nil)
 		return ExitInvalidArgs
 	}
@@ -32,27 +73,282 @@ func runConvert(args []string) int {
 		return ExitInvalidArgs
 	}
 
-	// Check input file exists
-	if _, err := os.Stat(*inputPath); os.IsNotExist(err) {
-		printError("FILE_NOT_FOUND", fmt.Sprintf("Input file not found: %s", *inputPath), map[string]interface{}{
-			"file": *inputPath,
+	if !types.IsRaggedPolicy(*raggedRows) {
+		printError("INVALID_RAGGED_POLICY", fmt.Sprintf("Unknown ragged row policy: %s", *raggedRows), map[string]interface{}{
+			"ragged_rows": *raggedRows,
+		})
+		return ExitInvalidArgs
+	}
+
+	if !types.IsSamplingStrategy(*sampling) {
+		printError("INVALID_SAMPLING", fmt.Sprintf("Unknown sampling strategy: %s", *sampling), map[string]interface{}{
+			"sampling": *sampling,
+		})
+		return ExitInvalidArgs
+	}
+
+	if !types.IsLocale(*locale) {
+		printError("INVALID_LOCALE", fmt.Sprintf("Unknown locale: %s", *locale), map[string]interface{}{
+			"locale": *locale,
+		})
+		return ExitInvalidArgs
+	}
+
+	if *where != "" {
+		if err := qcparser.CheckWhere(*where); err != nil {
+			printError("INVALID_WHERE", err.Error(), map[string]interface{}{
+				"where": *where,
+			})
+			return ExitInvalidArgs
+		}
+	}
+
+	var transforms []types.TransformStep
+	if *transformPath != "" {
+		var err error
+		if transforms, err = qcparser.LoadTransforms(*transformPath); err != nil {
+			printError("TRANSFORM_INVALID", err.Error(), map[string]interface{}{
+				"transform": *transformPath,
+			})
+			return ExitInvalidArgs
+		}
+	}
+
+	maskRules, err := parseMasks(masks)
+	if err == nil {
+		err = qcparser.CheckMasks(maskRules, *maskSalt)
+	}
+	if err != nil {
+		printError("INVALID_MASK", err.Error(), map[string]interface{}{
+			"mask": masks.String(),
+		})
+		return ExitInvalidArgs
+	}
+
+	// Expand globs into the list of input files
+	inputPaths, multi, err := resolveInputs(inputs)
+	if err != nil {
+		printError("FILE_NOT_FOUND", err.Error(), map[string]interface{}{
+			"input": []string(inputs),
 		})
 		return ExitFileNotFound
 	}
+	multi = multi || *perInput || *sourceColumn
+	inputPath := inputPaths[0]
+
+	if multi {
+		for _, path := range inputPaths {
+			if path == stdioPath {
+				printError("INVALID_INPUT", "stdin cannot be combined with other inputs", nil)
+				return ExitInvalidArgs
+			}
+		}
+		if *outputPath == stdioPath {
+			printError("INVALID_OUTPUT", "Several inputs cannot be written to stdout", nil)
+			return ExitInvalidArgs
+		}
+	}
+
+	dedupe := *dedupeOn != "" || *dedupeRows
+	if *dedupeOn != "" && *dedupeRows {
+		printError("INVALID_DEDUPE", "Use either --dedupe-on or --dedupe, not both", nil)
+		return ExitInvalidArgs
+	}
+	if !types.IsDedupeKeep(*dedupeKeep) {
+		printError("INVALID_DEDUPE", fmt.Sprintf("Unknown --dedupe-keep choice: %s", *dedupeKeep), map[string]interface{}{
+			"dedupe_keep": *dedupeKeep,
+		})
+		return ExitInvalidArgs
+	}
+	if dedupe && (multi || *follow) {
+		printError("INVALID_DEDUPE", "Deduplication needs a single input and cannot be combined with --follow", nil)
+		return ExitInvalidArgs
+	}
+
+	sortKeys, err := parseSortKeys(*sortBy)
+	if err != nil {
+		printError("INVALID_SORT", err.Error(), map[string]interface{}{
+			"sort_by": *sortBy,
+		})
+		return ExitInvalidArgs
+	}
+	if len(sortKeys) > 0 && (multi || *follow) {
+		printError("INVALID_SORT", "Sorting needs a single input and cannot be combined with --follow", nil)
+		return ExitInvalidArgs
+	}
+
+	partitionKeys, err := parsePartitionKeys(*partitionBy)
+	if err != nil {
+		printError("INVALID_PARTITION", err.Error(), map[string]interface{}{
+			"partition_by": *partitionBy,
+		})
+		return ExitInvalidArgs
+	}
+	if *maxRowsPerFile < 0 || *maxBytesPerFile < 0 {
+		printError("INVALID_SHARD", "--max-rows-per-file and --max-bytes-per-file must not be negative", nil)
+		return ExitInvalidArgs
+	}
+	sharded := *maxRowsPerFile > 0 || *maxBytesPerFile > 0 || len(partitionKeys) > 0
+	if sharded && (multi || *follow || *outputPath == stdioPath) {
+		printError("INVALID_SHARD", "Sharded or partitioned output needs a single input, an output directory and no --follow", nil)
+		return ExitInvalidArgs
+	}
+
+	if *follow {
+		if multi || inputPath == stdioPath || *outputPath == stdioPath {
+			printError("INVALID_FOLLOW", "--follow needs a single input file and an output file", nil)
+			return ExitInvalidArgs
+		}
+		if *pollInterval <= 0 {
+			printError("INVALID_POLL_INTERVAL", "--poll-interval must be positive", nil)
+			return ExitInvalidArgs
+		}
+	}
+
+	// Check input file exists
+	if !multi && inputPath != stdioPath {
+		if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+			printError("FILE_NOT_FOUND", fmt.Sprintf("Input file not found: %s", inputPath), map[string]interface{}{
+				"file": inputPath,
+			})
+			return ExitFileNotFound
+		}
+	}
 
 	// Check output directory is writable
-	outputDir := filepath.Dir(*outputPath)
-	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
-		printError("OUTPUT_DIR_NOT_FOUND", fmt.Sprintf("Output directory not found: %s", outputDir), nil)
+	if sharded {
+		if entries, err := os.ReadDir(*outputPath); err == nil && len(entries) > 0 {
+			printError("OUTPUT_DIR_NOT_EMPTY", fmt.Sprintf("Output directory is not empty: %s", *outputPath), nil)
+			return ExitInvalidArgs
+		}
+		if err := os.MkdirAll(*outputPath, 0755); err != nil {
+			printError("OUTPUT_DIR_INVALID", err.Error(), map[string]interface{}{
+				"output": *outputPath,
+			})
+			return ExitInvalidArgs
+		}
+	} else if *perInput {
+		if err := os.MkdirAll(*outputPath, 0755); err != nil {
+			printError("OUTPUT_DIR_INVALID", err.Error(), map[string]interface{}{
+				"output": *outputPath,
+			})
+			return ExitInvalidArgs
+		}
+	} else if info, err := os.Stat(*outputPath); err == nil && info.IsDir() {
+		printError("OUTPUT_IS_DIR", fmt.Sprintf("Output is a directory, use --per-input to write one DJSON per input: %s", *outputPath), nil)
 		return ExitInvalidArgs
+	} else if *outputPath != stdioPath {
+		outputDir := filepath.Dir(*outputPath)
+		if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+			printError("OUTPUT_DIR_NOT_FOUND", fmt.Sprintf("Output directory not found: %s", outputDir), nil)
+			return ExitInvalidArgs
+		}
+	} else {
+		// DJSON goes to stdout, so progress events move to stderr
+		eventOut = os.Stderr
+	}
+
+	// Create options
+	opts := types.DefaultOptions()
+	opts.SkipRows = *skipRows
+	opts.RaggedRows = *raggedRows
+	opts.Sampling = *sampling
+	opts.Locale = *locale
+	if code := applyConfig(*configPath, &opts); code != ExitSuccess {
+		return code
 	}
 
+	// Load pinned schema
+	if *schemaPath != "" {
+		schema, err := detector.LoadSchema(*schemaPath)
+		if err != nil {
+			printError("SCHEMA_INVALID", err.Error(), map[string]interface{}{
+				"schema": *schemaPath,
+			})
+			return ExitInvalidArgs
+		}
+		opts.Schema = schema
+	}
+
+	if *sourceColumn {
+		opts.SourceColumn = types.SourceFileColumn
+	}
+	opts.Select = splitList(*selectColumns)
+	opts.Where = *where
+	opts.Transforms = transforms
+	opts.Masks = maskRules
+	opts.MaskSalt = *maskSalt
+	opts.DedupeOn = splitList(*dedupeOn)
+	opts.DedupeRows = *dedupeRows
+	opts.DedupeKeep = *dedupeKeep
+	opts.SortBy = sortKeys
+	opts.MaxRowsPerFile = *maxRowsPerFile
+	opts.MaxBytesPerFile = *maxBytesPerFile
+	opts.PartitionBy = partitionKeys
+	opts.MemoryBytes = *memoryBytes
+	opts.TempDir = *tempDir
+	opts.CacheDir = *cacheDir
+	opts.CacheMaxBytes = *cacheMaxBytes
+
 	// Run conversion with progress tracking
-	return runStreamingConvert(*inputPath, *outputPath)
+	if *follow {
+		return runFollowConvert(inputPath, *outputPath, *pollInterval, &opts)
+	}
+	if multi {
+		return runDatasetConvert(inputPaths, *outputPath, &opts)
+	}
+	return runStreamingConvert(inputPath, *outputPath, &opts)
+}
+
+// runDatasetConvert converts several inputs into one dataset and emits NDJSON events
+func runDatasetConvert(inputPaths []string, outputPath string, opts *types.Options) int {
+	start := time.Now()
+
+	emitEvent("started", map[string]interface{}{
+		"input_paths": inputPaths,
+		"output_path": outputPath,
+	})
+
+	result, err := qcparser.ConvertMany(inputPaths, outputPath, opts)
+	if err != nil {
+		printError("CONVERSION_FAILED", err.Error(), nil)
+		return ExitConversionFailed
+	}
+
+	// Report skipped inputs before the per-input results
+	for _, skipped := range result.Incompatible {
+		emitEvent("incompatible", map[string]interface{}{
+			"path":   skipped.Path,
+			"reason": skipped.Reason,
+		})
+	}
+	for _, input := range result.Inputs {
+		emitEvent("input", map[string]interface{}{
+			"path":          input.Path,
+			"djson_path":    input.DJSONPath,
+			"rows_written":  input.RowsWritten,
+			"bytes_written": input.BytesWritten,
+			"errors":        input.Errors,
+			"ragged_rows":   input.RaggedRows,
+		})
+	}
+
+	emitEvent("result", map[string]interface{}{
+		"djson_path":    result.DJSONPath,
+		"rows_written":  result.RowsWritten,
+		"bytes_written": result.BytesWritten,
+		"duration_ms":   time.Since(start).Milliseconds(),
+		"columns":       result.Columns,
+		"inputs":        len(result.Inputs),
+		"incompatible":  len(result.Incompatible),
+		"issues":        result.Issues,
+	})
+
+	return ExitSuccess
 }
 
 // runStreamingConvert runs conversion and emits NDJSON progress events
-func runStreamingConvert(inputPath, outputPath string) int {
+func runStreamingConvert(inputPath, outputPath string, opts *types.Options) int {
 	start := time.Now()
 
 	// Emit started event
@@ -61,30 +357,126 @@ func runStreamingConvert(inputPath, outputPath string) int {
 		"output_path": outputPath,
 	})
 
-	// Create options
-	opts := types.DefaultOptions()
-
 	// Run conversion (this internally does detect → read → write)
 	// We'll get progress from the reader's error channel
-	result, err := qcparser.Convert(inputPath, outputPath, &opts)
+	var result *types.ConvertResult
+	var err error
+	if inputPath == stdioPath || outputPath == stdioPath {
+		result, err = convertStdio(inputPath, outputPath, opts)
+	} else {
+		result, err = qcparser.Convert(inputPath, outputPath, opts)
+	}
+	if errors.Is(err, detector.ErrSchemaMismatch) {
+		printError("SCHEMA_MISMATCH", err.Error(), map[string]interface{}{
+			"file": inputPath,
+		})
+		return ExitSchemaMismatch
+	}
 	if err != nil {
 		printError("CONVERSION_FAILED", err.Error(), nil)
 		return ExitConversionFailed
 	}
 
 	// Emit final result
+	emitEvent("result", map[string]interface{}{
+		"djson_path":         result.DJSONPath,
+		"rows_written":       result.RowsWritten,
+		"rows_filtered":      result.RowsFiltered,
+		"bytes_written":      result.BytesWritten,
+		"duration_ms":        time.Since(start).Milliseconds(),
+		"duplicates_removed": result.DuplicatesRemoved,
+		"errors":             result.Errors, // Include collected errors
+		"ragged_rows":        result.RaggedRows,
+		"cast_failures":      result.CastFailures,
+		"cached":             result.Cached,
+		"files":              result.Files,
+	})
+
+	return ExitSuccess
+}
+
+// runFollowConvert follows a growing input until interrupted, emitting an
+// NDJSON event for each appended batch, rotation or truncation
+func runFollowConvert(inputPath, outputPath string, interval time.Duration, opts *types.Options) int {
+	emitEvent("started", map[string]interface{}{
+		"input_path":  inputPath,
+		"output_path": outputPath,
+		"state_path":  qcparser.FollowStatePath(outputPath),
+	})
+
+	// Stop following on interrupt; the offset is already saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := qcparser.Follow(ctx, inputPath, outputPath, opts, interval, func(event types.FollowEvent) {
+		emitEvent(event.Type, map[string]interface{}{
+			"offset":        event.Offset,
+			"rows_appended": event.RowsAppended,
+			"rows_written":  event.RowsWritten,
+			"bytes_written": event.BytesWritten,
+			"errors":        event.Errors,
+			"ragged_rows":   event.RaggedRows,
+		})
+	})
+	if err != nil {
+		printError("CONVERSION_FAILED", err.Error(), nil)
+		return ExitConversionFailed
+	}
+
 	emitEvent("result", map[string]interface{}{
 		"djson_path":    result.DJSONPath,
 		"rows_written":  result.RowsWritten,
+		"rows_filtered": result.RowsFiltered,
 		"bytes_written": result.BytesWritten,
-		"duration_ms":   time.Since(start).Milliseconds(),
-		"errors":        result.Errors, // Include collected errors
+		"duration_ms":   result.DurationMs,
+		"ragged_rows":   result.RaggedRows,
 	})
 
 	return ExitSuccess
 }
 
-// emitEvent outputs an NDJSON event to stdout
+// convertStdio runs a streaming conversion when input or output is "-"
+func convertStdio(inputPath, outputPath string, opts *types.Options) (*types.ConvertResult, error) {
+	var in io.Reader = os.Stdin
+	if inputPath != stdioPath {
+		file, err := os.Open(inputPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var out io.Writer = os.Stdout
+	if outputPath != stdioPath {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	// Buffer stdout, it is unbuffered by default
+	buffered := bufio.NewWriter(out)
+	result, err := qcparser.ConvertStream(in, buffered, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return nil, err
+	}
+
+	if outputPath != stdioPath {
+		result.DJSONPath = outputPath
+	}
+	return result, nil
+}
+
+// eventOut receives NDJSON events; stderr when DJSON is written to stdout
+var eventOut io.Writer = os.Stdout
+
+// emitEvent outputs an NDJSON event to eventOut
 func emitEvent(eventType string, data map[string]interface{}) {
 	event := map[string]interface{}{
 		"type": eventType,
@@ -96,5 +488,116 @@ func emitEvent(eventType string, data map[string]interface{}) {
 	}
 
 	eventJSON, _ := json.Marshal(event)
-	fmt.Println(string(eventJSON))
+	fmt.Fprintln(eventOut, string(eventJSON))
+}
+
+// inputList collects repeated --input flags
+type inputList []string
+
+func (l *inputList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *inputList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// maskList collects repeated --mask flags
+type maskList []string
+
+func (l *maskList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *maskList) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// parseMasks parses --mask values such as "email:hash" or "card:last:4"
+func parseMasks(values []string) ([]types.MaskRule, error) {
+	var rules []types.MaskRule
+	for _, value := range values {
+		name, strategy, _ := strings.Cut(value, ":")
+		strategy, keep, hasKeep := strings.Cut(strategy, ":")
+		rule := types.MaskRule{Column: strings.TrimSpace(name), Strategy: strings.ToLower(strings.TrimSpace(strategy))}
+		if hasKeep {
+			n, err := strconv.Atoi(strings.TrimSpace(keep))
+			if err != nil || rule.Strategy != types.MaskLast {
+				return nil, fmt.Errorf("invalid mask %q, use col:last:N to keep the last N characters", value)
+			}
+			rule.Keep = n
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// resolveInputs expands glob patterns and reports whether the inputs form a dataset
+func resolveInputs(inputs []string) ([]string, bool, error) {
+	var paths []string
+	multi := len(inputs) > 1
+
+	for _, input := range inputs {
+		if input == stdioPath || !strings.ContainsAny(input, "*?[") {
+			paths = append(paths, input)
+			continue
+		}
+
+		matches, err := filepath.Glob(input)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid glob %q: %w", input, err)
+		}
+		if len(matches) == 0 {
+			return nil, false, fmt.Errorf("no files match %s", input)
+		}
+		paths = append(paths, matches...)
+		multi = true
+	}
+
+	return paths, multi, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseSortKeys parses a --sort-by value such as "ts,host:desc"
+func parseSortKeys(value string) ([]types.SortKey, error) {
+	var keys []types.SortKey
+	for _, item := range splitList(value) {
+		name, direction, _ := strings.Cut(item, ":")
+		key := types.SortKey{Column: strings.TrimSpace(name)}
+		switch strings.ToLower(strings.TrimSpace(direction)) {
+		case "", "asc":
+		case "desc":
+			key.Desc = true
+		default:
+			return nil, fmt.Errorf("unknown sort direction %q for column %s, use asc or desc", direction, key.Column)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parsePartitionKeys parses a --partition-by value such as "ts:month,country"
+func parsePartitionKeys(value string) ([]types.PartitionKey, error) {
+	var keys []types.PartitionKey
+	for _, item := range splitList(value) {
+		name, bucket, _ := strings.Cut(item, ":")
+		key := types.PartitionKey{Column: strings.TrimSpace(name), Bucket: strings.ToLower(strings.TrimSpace(bucket))}
+		if !types.IsPartitionBucket(key.Bucket) {
+			return nil, fmt.Errorf("unknown partition bucket %q for column %s, use day, month or year", bucket, key.Column)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
 }
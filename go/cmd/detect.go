@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"querycraft/pkg/qcparser"
 	"querycraft/pkg/qcparser/detector"
 	"querycraft/pkg/qcparser/types"
 )
@@ -14,9 +15,17 @@ func runDetect(args []string) int {
 	fs := flag.NewFlagSet("detect", flag.ExitOnError)
 
 	// Define flags
-	filePath := fs.String("file", "", "Path to file to detect (required)")
+	filePath := fs.String("file", "", "Path to file to detect, or - for stdin (required)")
 	sampleBytes := fs.Int64("sample-bytes", 1<<20, "Sample size in bytes (default: 1MB)")
 	maxPreviewRows := fs.Int("max-preview-rows", 50, "Maximum preview rows (default: 50)")
+	skipRows := fs.Int("skip-rows", -1, "Number of preamble lines to skip (default: auto-detect)")
+	raggedRows := fs.String("ragged-rows", types.RaggedReject, "Ragged row policy: reject, pad, truncate or extra")
+	sampling := fs.String("sampling", types.SampleHead, "Detection sampling strategy: head, stratified or reservoir")
+	locale := fs.String("locale", "", "Locale of numbers and month names: en, de, fr, es, it, pt or nl (default: detected per column)")
+	sampleRegions := fs.Int("sample-regions", 8, "Number of regions read by stratified sampling")
+	configPath := fs.String("config", "", "JSON config file with detection heuristics")
+	explain := fs.Bool("explain", false, "Include delimiter scores, header evidence and type votes")
+	transformPath := fs.String("transform", "", "JSON transform spec; columns and preview show the transformed rows")
 
 	// Parse flags
 	fs.Parse(args)
@@ -28,27 +37,81 @@ func runDetect(args []string) int {
 		return ExitInvalidArgs
 	}
 
-	// Check file exists
-	if _, err := os.Stat(*filePath); os.IsNotExist(err) {
-		printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", *filePath), map[string]interface{}{
-			"file": *filePath,
+	if !types.IsRaggedPolicy(*raggedRows) {
+		printError("INVALID_RAGGED_POLICY", fmt.Sprintf("Unknown ragged row policy: %s", *raggedRows), map[string]interface{}{
+			"ragged_rows": *raggedRows,
+		})
+		return ExitInvalidArgs
+	}
+
+	if !types.IsSamplingStrategy(*sampling) {
+		printError("INVALID_SAMPLING", fmt.Sprintf("Unknown sampling strategy: %s", *sampling), map[string]interface{}{
+			"sampling": *sampling,
+		})
+		return ExitInvalidArgs
+	}
+
+	if !types.IsLocale(*locale) {
+		printError("INVALID_LOCALE", fmt.Sprintf("Unknown locale: %s", *locale), map[string]interface{}{
+			"locale": *locale,
 		})
-		return ExitFileNotFound
+		return ExitInvalidArgs
+	}
+
+	// Check file exists
+	if *filePath != stdioPath {
+		if _, err := os.Stat(*filePath); os.IsNotExist(err) {
+			printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", *filePath), map[string]interface{}{
+				"file": *filePath,
+			})
+			return ExitFileNotFound
+		}
 	}
 
 	// Create detection options
 	opts := types.DefaultOptions()
 	opts.SampleBytes = *sampleBytes
 	opts.MaxPreviewRows = *maxPreviewRows
+	opts.SkipRows = *skipRows
+	opts.RaggedRows = *raggedRows
+	opts.Sampling = *sampling
+	opts.Locale = *locale
+	opts.SampleRegions = *sampleRegions
+	opts.Explain = *explain
+	if code := applyConfig(*configPath, &opts); code != ExitSuccess {
+		return code
+	}
+	if *transformPath != "" {
+		transforms, err := qcparser.LoadTransforms(*transformPath)
+		if err != nil {
+			printError("TRANSFORM_INVALID", err.Error(), map[string]interface{}{
+				"transform": *transformPath,
+			})
+			return ExitInvalidArgs
+		}
+		opts.Transforms = transforms
+	}
 
-	// Run detection
-	result, err := detector.Detect(*filePath, &opts)
+	// Run detection, reading stdin when --file is "-"
+	var result *types.DetectResponse
+	var err error
+	if *filePath == stdioPath {
+		result, _, err = detector.DetectReader(os.Stdin, &opts)
+	} else {
+		result, err = detector.Detect(*filePath, &opts)
+	}
 	if err != nil {
 		printError("DETECTION_FAILED", err.Error(), map[string]interface{}{
 			"file": *filePath,
 		})
 		return ExitDetectionFailed
 	}
+	if result, err = qcparser.PreviewTransforms(result, &opts); err != nil {
+		printError("TRANSFORM_INVALID", err.Error(), map[string]interface{}{
+			"transform": *transformPath,
+		})
+		return ExitInvalidArgs
+	}
 
 	// Marshal result to JSON
 	output, err := json.Marshal(result)
@@ -85,4 +148,7 @@ const (
 	ExitFileNotFound     = 3
 	ExitDetectionFailed  = 4
 	ExitConversionFailed = 5
+	ExitProfileFailed    = 6
+	ExitSchemaMismatch   = 7
+	ExitValidationFailed = 8
 )
@@ -3,9 +3,13 @@ package main
 import (
 	"fmt"
 	"os"
+	"querycraft/pkg/qcparser/types"
 )
 
-const version = "1.0.0"
+const version = types.ToolVersion
+
+// stdioPath selects stdin or stdout in place of a file path
+const stdioPath = "-"
 
 func main() {
 	// Require at least one argument (command)
@@ -23,6 +27,16 @@ func main() {
 		os.Exit(runDetect(os.Args[2:]))
 	case "convert":
 		os.Exit(runConvert(os.Args[2:]))
+	case "profile":
+		os.Exit(runProfile(os.Args[2:]))
+	case "schema":
+		os.Exit(runSchema(os.Args[2:]))
+	case "validate":
+		os.Exit(runValidate(os.Args[2:]))
+	case "serve":
+		os.Exit(runServe(os.Args[2:]))
+	case "cache":
+		os.Exit(runCache(os.Args[2:]))
 	case "version":
 		fmt.Printf("qcparser v%s\n", version)
 		os.Exit(0)
@@ -45,12 +59,32 @@ Usage:
 Commands:
   detect   Detect file format and structure
   convert  Convert file to DJSON format
+  profile  Compute full-file column statistics
+  schema   Generate DuckDB DDL and read clause from detection
+  validate Check a file against a data contract
+  serve    Serve detect, convert, preview and profile over JSON-RPC or HTTP
+  cache    List or clear cached conversions (ls, clear)
   version  Show version information
   help     Show this help message
 
 Examples:
   qcparser detect --file=/path/to/file.csv
+  qcparser detect --file=file.csv --explain --config=qcparser.json
   qcparser convert --input=file.csv --output=file.djson
+  zcat file.csv.gz | qcparser convert --input - --output -
+  qcparser convert --input='orders_2026-*.csv' --output=orders.djson --source-column
+  qcparser convert --input=app.log --output=errors.djson --select=ts,msg --where="level IN ('ERROR','FATAL')"
+  qcparser convert --input=events.csv --output=events/ --partition-by=ts:month,country --max-rows-per-file=1000000
+  qcparser convert --input=users.csv --output=users.djson --transform=transforms.json
+  QCPARSER_MASK_SALT=... qcparser convert --input=customers.csv --output=customers.djson --mask=email:hash,card:last:4,name:redact
+  qcparser convert --input=umsatz.csv --output=umsatz.djson --locale=de
+  qcparser convert --input=big.csv --output=big.djson --cache-dir=$HOME/.cache/querycraft/djson
+  qcparser profile --file=/path/to/file.csv
+  qcparser schema --file=file.csv --djson=file.djson --table=logs
+  qcparser validate --file=file.csv --schema=contract.json
+  qcparser serve --stdio
+  qcparser serve --http=:8080
+  qcparser cache ls
 
 Run 'qcparser <command> --help' for more information on a command.`)
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser/profiler"
+	"querycraft/pkg/qcparser/types"
+)
+
+func runProfile(args []string) int {
+	// Create flag set for profile command
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+
+	// Define flags
+	filePath := fs.String("file", "", "Path to file to profile, or - for stdin (required)")
+	topK := fs.Int("top-k", 10, "Number of most frequent values per column (default: 10)")
+	histogramBins := fs.Int("histogram-bins", 20, "Number of histogram buckets for numeric columns (default: 20)")
+	skipRows := fs.Int("skip-rows", -1, "Number of preamble lines to skip (default: auto-detect)")
+	raggedRows := fs.String("ragged-rows", types.RaggedReject, "Ragged row policy: reject, pad, truncate or extra")
+	sampling := fs.String("sampling", types.SampleHead, "Detection sampling strategy: head, stratified or reservoir")
+	locale := fs.String("locale", "", "Locale of numbers and month names: en, de, fr, es, it, pt or nl (default: detected per column)")
+	configPath := fs.String("config", "", "JSON config file with detection heuristics")
+
+	// Parse flags
+	fs.Parse(args)
+
+	// Validate required flags
+	if *filePath == "" {
+		printError("FILE_REQUIRED", "The --file flag is required", nil)
+		fmt.Fprintln(os.Stderr, "\nUsage: qcparser profile --file=/path/to/file.csv")
+		return ExitInvalidArgs
+	}
+
+	if !types.IsRaggedPolicy(*raggedRows) {
+		printError("INVALID_RAGGED_POLICY", fmt.Sprintf("Unknown ragged row policy: %s", *raggedRows), map[string]interface{}{
+			"ragged_rows": *raggedRows,
+		})
+		return ExitInvalidArgs
+	}
+
+	if !types.IsSamplingStrategy(*sampling) {
+		printError("INVALID_SAMPLING", fmt.Sprintf("Unknown sampling strategy: %s", *sampling), map[string]interface{}{
+			"sampling": *sampling,
+		})
+		return ExitInvalidArgs
+	}
+
+	if !types.IsLocale(*locale) {
+		printError("INVALID_LOCALE", fmt.Sprintf("Unknown locale: %s", *locale), map[string]interface{}{
+			"locale": *locale,
+		})
+		return ExitInvalidArgs
+	}
+
+	// Check file exists
+	if *filePath != stdioPath {
+		if _, err := os.Stat(*filePath); os.IsNotExist(err) {
+			printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", *filePath), map[string]interface{}{
+				"file": *filePath,
+			})
+			return ExitFileNotFound
+		}
+	}
+
+	// Create profiling options
+	opts := types.DefaultOptions()
+	opts.TopK = *topK
+	opts.HistogramBins = *histogramBins
+	opts.SkipRows = *skipRows
+	opts.RaggedRows = *raggedRows
+	opts.Sampling = *sampling
+	opts.Locale = *locale
+	if code := applyConfig(*configPath, &opts); code != ExitSuccess {
+		return code
+	}
+
+	// Run profiling over the whole input
+	var result *types.ProfileResponse
+	var err error
+	if *filePath == stdioPath {
+		result, err = profiler.ProfileReader(os.Stdin, &opts)
+	} else {
+		result, err = profiler.Profile(*filePath, &opts)
+	}
+	if err != nil {
+		printError("PROFILE_FAILED", err.Error(), map[string]interface{}{
+			"file": *filePath,
+		})
+		return ExitProfileFailed
+	}
+
+	// Marshal result to JSON
+	output, err := json.Marshal(result)
+	if err != nil {
+		printError("JSON_MARSHAL_ERROR", err.Error(), nil)
+		return ExitGeneralError
+	}
+
+	fmt.Println(string(output))
+
+	return ExitSuccess
+}
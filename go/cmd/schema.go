@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/duckdb"
+	"querycraft/pkg/qcparser/types"
+)
+
+func runSchema(args []string) int {
+	// Create flag set for schema command
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+
+	// Define flags
+	filePath := fs.String("file", "", "Path to source file to detect (required)")
+	table := fs.String("table", "data", "Table name for the generated statements (default: data)")
+	djsonPath := fs.String("djson", "", "Path of the converted DJSON to load (default: load the source file)")
+	sampleBytes := fs.Int64("sample-bytes", 1<<20, "Sample size in bytes (default: 1MB)")
+	skipRows := fs.Int("skip-rows", -1, "Number of preamble lines to skip (default: auto-detect)")
+	raggedRows := fs.String("ragged-rows", types.RaggedReject, "Ragged row policy: reject, pad, truncate or extra")
+	sampling := fs.String("sampling", types.SampleHead, "Detection sampling strategy: head, stratified or reservoir")
+	locale := fs.String("locale", "", "Locale of numbers and month names: en, de, fr, es, it, pt or nl (default: detected per column)")
+	sampleRegions := fs.Int("sample-regions", 8, "Number of regions read by stratified sampling")
+	configPath := fs.String("config", "", "JSON config file with detection heuristics")
+
+	// Parse flags
+	fs.Parse(args)
+
+	// Validate required flags
+	if *filePath == "" {
+		printError("FILE_REQUIRED", "The --file flag is required", nil)
+		fmt.Fprintln(os.Stderr, "\nUsage: qcparser schema --file=/path/to/file.csv [--djson=/path/to/file.djson] [--table=name]")
+		return ExitInvalidArgs
+	}
+
+	if !types.IsRaggedPolicy(*raggedRows) {
+		printError("INVALID_RAGGED_POLICY", fmt.Sprintf("Unknown ragged row policy: %s", *raggedRows), map[string]interface{}{
+			"ragged_rows": *raggedRows,
+		})
+		return ExitInvalidArgs
+	}
+
+	if !types.IsSamplingStrategy(*sampling) {
+		printError("INVALID_SAMPLING", fmt.Sprintf("Unknown sampling strategy: %s", *sampling), map[string]interface{}{
+			"sampling": *sampling,
+		})
+		return ExitInvalidArgs
+	}
+
+	if !types.IsLocale(*locale) {
+		printError("INVALID_LOCALE", fmt.Sprintf("Unknown locale: %s", *locale), map[string]interface{}{
+			"locale": *locale,
+		})
+		return ExitInvalidArgs
+	}
+
+	// Check file exists
+	if _, err := os.Stat(*filePath); os.IsNotExist(err) {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", *filePath), map[string]interface{}{
+			"file": *filePath,
+		})
+		return ExitFileNotFound
+	}
+
+	// Create detection options
+	opts := types.DefaultOptions()
+	opts.SampleBytes = *sampleBytes
+	opts.SkipRows = *skipRows
+	opts.RaggedRows = *raggedRows
+	opts.Sampling = *sampling
+	opts.Locale = *locale
+	opts.SampleRegions = *sampleRegions
+	if code := applyConfig(*configPath, &opts); code != ExitSuccess {
+		return code
+	}
+
+	// Run detection
+	detected, err := detector.Detect(*filePath, &opts)
+	if err != nil {
+		printError("DETECTION_FAILED", err.Error(), map[string]interface{}{
+			"file": *filePath,
+		})
+		return ExitDetectionFailed
+	}
+
+	// Load the DJSON when given, otherwise the source file itself
+	target, path := duckdb.TargetSource, *filePath
+	if *djsonPath != "" {
+		target, path = duckdb.TargetDJSON, *djsonPath
+	}
+
+	result, err := duckdb.Schema(detected, *table, path, target, *raggedRows)
+	if err != nil {
+		printError("SCHEMA_FAILED", err.Error(), map[string]interface{}{
+			"file": *filePath,
+		})
+		return ExitGeneralError
+	}
+
+	// Marshal result to JSON
+	output, err := json.Marshal(result)
+	if err != nil {
+		printError("JSON_MARSHAL_ERROR", err.Error(), nil)
+		return ExitGeneralError
+	}
+
+	fmt.Println(string(output))
+
+	return ExitSuccess
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"querycraft/pkg/qcparser/server"
+)
+
+func runServe(args []string) int {
+	// Create flag set for serve command
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+
+	// Define flags
+	stdio := fs.Bool("stdio", false, "Serve JSON-RPC 2.0 on stdin/stdout, one message per line")
+	httpAddr := fs.String("http", "", "Serve the HTTP API on this address, e.g. :8080")
+	maxUpload := fs.Int64("max-upload-bytes", server.DefaultHTTPConfig().MaxUploadBytes, "Largest accepted HTTP upload in bytes")
+	maxConcurrent := fs.Int("max-concurrent", server.DefaultHTTPConfig().MaxConcurrent, "HTTP requests and jobs running at once")
+	tempDir := fs.String("temp-dir", "", "Directory for HTTP job uploads and outputs (default: system temp)")
+	jobTTL := fs.Duration("job-ttl", server.DefaultHTTPConfig().JobTTL, "How long finished HTTP jobs and their output are kept, 0 to keep them until deleted")
+
+	// Parse flags
+	fs.Parse(args)
+
+	if *stdio == (*httpAddr != "") {
+		printError("TRANSPORT_REQUIRED", "Exactly one of --stdio or --http is required", nil)
+		fmt.Fprintln(os.Stderr, "\nUsage: qcparser serve --stdio | --http=:8080")
+		return ExitInvalidArgs
+	}
+
+	// Stop serving on interrupt; running jobs are cancelled
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *httpAddr != "" {
+		handler := server.NewHTTPServer(server.HTTPConfig{
+			MaxUploadBytes: *maxUpload,
+			MaxConcurrent:  *maxConcurrent,
+			TempDir:        *tempDir,
+			JobTTL:         *jobTTL,
+		})
+		err := server.ListenAndServe(ctx, *httpAddr, handler)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			printError("SERVE_FAILED", err.Error(), nil)
+			return ExitGeneralError
+		}
+		return ExitSuccess
+	}
+
+	// Each message is encoded with a single write to the unbuffered stdout
+	rpc := server.NewRPCServer(os.Stdout)
+
+	err := rpc.Serve(ctx, os.Stdin)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		printError("SERVE_FAILED", err.Error(), nil)
+		return ExitGeneralError
+	}
+
+	return ExitSuccess
+}
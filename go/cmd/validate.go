@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/types"
+	"querycraft/pkg/qcparser/validator"
+)
+
+func runValidate(args []string) int {
+	// Create flag set for validate command
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+
+	// Define flags
+	filePath := fs.String("file", "", "Path to file to validate, or - for stdin (required)")
+	schemaPath := fs.String("schema", "", "Data contract JSON file (required)")
+	raggedRows := fs.String("ragged-rows", types.RaggedReject, "Ragged row policy: reject, pad, truncate or extra")
+	sampling := fs.String("sampling", types.SampleHead, "Detection sampling strategy: head, stratified or reservoir")
+	locale := fs.String("locale", "", "Locale of numbers and month names: en, de, fr, es, it, pt or nl (default: detected per column)")
+	memoryBytes := fs.Int64("memory-bytes", 256<<20, "Memory held by unique and key checks before spilling to disk (default: 256MB)")
+	tempDir := fs.String("temp-dir", "", "Directory for spill files (default: system temp dir)")
+	configPath := fs.String("config", "", "JSON config file with detection heuristics")
+
+	// Parse flags
+	fs.Parse(args)
+
+	// Validate required flags
+	if *filePath == "" || *schemaPath == "" {
+		printError("ARGS_REQUIRED", "Both --file and --schema flags are required", nil)
+		fmt.Fprintln(os.Stderr, "\nUsage: qcparser validate --file=/path/to/file.csv --schema=contract.json")
+		return ExitInvalidArgs
+	}
+
+	if !types.IsRaggedPolicy(*raggedRows) {
+		printError("INVALID_RAGGED_POLICY", fmt.Sprintf("Unknown ragged row policy: %s", *raggedRows), map[string]interface{}{
+			"ragged_rows": *raggedRows,
+		})
+		return ExitInvalidArgs
+	}
+
+	if !types.IsSamplingStrategy(*sampling) {
+		printError("INVALID_SAMPLING", fmt.Sprintf("Unknown sampling strategy: %s", *sampling), map[string]interface{}{
+			"sampling": *sampling,
+		})
+		return ExitInvalidArgs
+	}
+
+	if !types.IsLocale(*locale) {
+		printError("INVALID_LOCALE", fmt.Sprintf("Unknown locale: %s", *locale), map[string]interface{}{
+			"locale": *locale,
+		})
+		return ExitInvalidArgs
+	}
+
+	// Check file exists
+	if *filePath != stdioPath {
+		if _, err := os.Stat(*filePath); os.IsNotExist(err) {
+			printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", *filePath), map[string]interface{}{
+				"file": *filePath,
+			})
+			return ExitFileNotFound
+		}
+	}
+
+	// Load the contract
+	contract, err := detector.LoadSchema(*schemaPath)
+	if err != nil {
+		printError("SCHEMA_INVALID", err.Error(), map[string]interface{}{
+			"schema": *schemaPath,
+		})
+		return ExitInvalidArgs
+	}
+
+	opts := types.DefaultOptions()
+	opts.RaggedRows = *raggedRows
+	opts.Sampling = *sampling
+	opts.Locale = *locale
+	opts.MemoryBytes = *memoryBytes
+	opts.TempDir = *tempDir
+	if code := applyConfig(*configPath, &opts); code != ExitSuccess {
+		return code
+	}
+
+	// Stream the input against the contract
+	var report *types.ValidationReport
+	if *filePath == stdioPath {
+		report, err = validator.ValidateReader(os.Stdin, contract, &opts)
+	} else {
+		report, err = validator.Validate(*filePath, contract, &opts)
+	}
+	if err != nil {
+		printError("VALIDATION_ERROR", err.Error(), map[string]interface{}{
+			"file": *filePath,
+		})
+		return ExitGeneralError
+	}
+
+	// Marshal report to JSON
+	output, err := json.Marshal(report)
+	if err != nil {
+		printError("JSON_MARSHAL_ERROR", err.Error(), nil)
+		return ExitGeneralError
+	}
+
+	fmt.Println(string(output))
+
+	if !report.Valid {
+		return ExitValidationFailed
+	}
+	return ExitSuccess
+}
@@ -0,0 +1,385 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"querycraft/pkg/qcparser/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxBytes is the cache size above which entries are evicted
+	DefaultMaxBytes = 10 << 30
+
+	// version changes whenever the DJSON output of a conversion changes
+	version = "djson-cache-v2"
+
+	// edgeBytes is the length of the input head and tail hashed into a key
+	edgeBytes = 64 << 10
+
+	djsonExt    = ".djson"
+	metaExt     = ".json"
+	manifestExt = ".manifest"
+)
+
+// Entry describes a cached conversion
+type Entry struct {
+	Key      string              `json:"key"`
+	Source   string              `json:"source"` // Input converted when the entry was stored
+	Bytes    int64               `json:"bytes"`
+	Created  time.Time           `json:"created"`
+	LastUsed time.Time           `json:"last_used"`
+	Result   types.ConvertResult `json:"result"`
+}
+
+// DefaultDir returns the per-user cache directory
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "querycraft", "djson")
+}
+
+// Key fingerprints an input and the options of its conversion: the size,
+// modification time and a hash of the head and tail of the file
+func Key(filePath string, opts *types.Options) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	options, err := json.Marshal(opts)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(version))
+	binary.Write(hash, binary.LittleEndian, info.Size())
+	binary.Write(hash, binary.LittleEndian, info.ModTime().UnixNano())
+	hash.Write(options)
+	// The salt is left out of the options, yet changes masked outputs
+	hash.Write([]byte(opts.MaskSalt))
+
+	// Head and tail overlap on small files, which is fine for a hash
+	if _, err := io.Copy(hash, io.LimitReader(file, edgeBytes)); err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(hash, io.NewSectionReader(file, max(info.Size()-edgeBytes, 0), edgeBytes)); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Lookup copies the cached output of key to outputPath, reporting whether
+// there was one. The result and manifest describe the copy made from source.
+func Lookup(dir, key, source, outputPath string) (*types.ConvertResult, bool, error) {
+	start := time.Now()
+
+	entry, err := readEntry(dir, key)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := copyFile(filepath.Join(dir, key+djsonExt), outputPath); err != nil {
+		return nil, false, err
+	}
+	// The manifest describes the conversion that produced the entry, with
+	// the paths of this input and output
+	manifest := filepath.Join(dir, key+manifestExt)
+	if _, err := os.Stat(manifest); err == nil {
+		err := copyManifest(manifest, types.ManifestPath(outputPath), func(m *types.Manifest) {
+			m.Files = resolveFiles(m.Files, outputPath)
+			if len(m.Sources) == 1 {
+				if abs, err := filepath.Abs(source); err == nil {
+					m.Sources[0].Path = abs
+				}
+			}
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Keep recently used entries from eviction
+	entry.LastUsed = time.Now()
+	if err := writeEntry(dir, entry); err != nil {
+		return nil, false, err
+	}
+
+	result := entry.Result
+	result.DJSONPath = outputPath
+	result.Files = resolveFiles(result.Files, outputPath)
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.Cached = true
+	if result.Errors == nil {
+		result.Errors = []string{}
+	}
+	return &result, true, nil
+}
+
+// Store adds the output of a conversion under key, then evicts the least
+// recently used entries until the cache holds at most maxBytes, or
+// DefaultMaxBytes when maxBytes is not positive
+func Store(dir, key, source string, result *types.ConvertResult, maxBytes int64) error {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, key+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := copyFile(result.DJSONPath, tmp.Name()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, key+djsonExt)); err != nil {
+		return err
+	}
+	// Output paths are kept relative to the output, which moves on lookup
+	if _, err := os.Stat(types.ManifestPath(result.DJSONPath)); err == nil {
+		err := copyManifest(types.ManifestPath(result.DJSONPath), filepath.Join(dir, key+manifestExt), func(m *types.Manifest) {
+			m.Files = relativeFiles(m.Files, result.DJSONPath)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if abs, err := filepath.Abs(source); err == nil {
+		source = abs
+	}
+
+	now := time.Now()
+	entry := &Entry{
+		Key:      key,
+		Source:   source,
+		Bytes:    result.BytesWritten,
+		Created:  now,
+		LastUsed: now,
+		Result:   *result,
+	}
+	entry.Result.DJSONPath = ""
+	entry.Result.Files = relativeFiles(result.Files, result.DJSONPath)
+	if err := writeEntry(dir, entry); err != nil {
+		return err
+	}
+
+	return Evict(dir, maxBytes)
+}
+
+// Evict removes the least recently used entries until the cache holds at
+// most maxBytes
+func Evict(dir string, maxBytes int64) error {
+	entries, err := List(dir)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Bytes
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsed.Before(entries[j].LastUsed) })
+	for _, entry := range entries {
+		if total <= maxBytes {
+			break
+		}
+		if err := remove(dir, entry.Key); err != nil {
+			return err
+		}
+		total -= entry.Bytes
+	}
+	return nil
+}
+
+// List returns the cached entries, most recently used first
+func List(dir string) ([]Entry, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+metaExt))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(names))
+	for _, name := range names {
+		entry, err := readEntry(dir, strings.TrimSuffix(filepath.Base(name), metaExt))
+		if err != nil {
+			// Entries being written or removed concurrently are skipped
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsed.After(entries[j].LastUsed) })
+	return entries, nil
+}
+
+// Clear removes every entry, returning how many were removed and their size
+func Clear(dir string) (int, int64, error) {
+	entries, err := List(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var freed int64
+	for _, entry := range entries {
+		if err := remove(dir, entry.Key); err != nil {
+			return 0, 0, err
+		}
+		freed += entry.Bytes
+	}
+
+	// Drop temporary files left by interrupted stores
+	tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	for _, tmp := range tmps {
+		os.Remove(tmp)
+	}
+
+	return len(entries), freed, nil
+}
+
+// readEntry reads the metadata of an entry whose output exists
+func readEntry(dir, key string) (*Entry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, key+metaExt))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, key+djsonExt)); err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// writeEntry atomically replaces the metadata of an entry
+func writeEntry(dir string, entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, entry.Key+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, entry.Key+metaExt))
+}
+
+// remove deletes an entry, metadata first so it is never listed half removed
+func remove(dir, key string) error {
+	if err := os.Remove(filepath.Join(dir, key+metaExt)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, key+djsonExt)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, key+manifestExt)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// relativeFiles returns files with their paths relative to the output at base
+func relativeFiles(files []types.OutputFile, base string) []types.OutputFile {
+	if files == nil {
+		return nil
+	}
+	relative := make([]types.OutputFile, len(files))
+	for i, file := range files {
+		relative[i] = file
+		if rel, err := filepath.Rel(base, file.Path); err == nil {
+			relative[i].Path = rel
+		}
+	}
+	return relative
+}
+
+// resolveFiles returns relative files with their paths under the output at base
+func resolveFiles(files []types.OutputFile, base string) []types.OutputFile {
+	if files == nil {
+		return nil
+	}
+	resolved := make([]types.OutputFile, len(files))
+	for i, file := range files {
+		resolved[i] = file
+		resolved[i].Path = filepath.Join(base, file.Path)
+	}
+	return resolved
+}
+
+// copyManifest copies the manifest at src to dst, changed by rewrite
+func copyManifest(src, dst string, rewrite func(*types.Manifest)) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	var manifest types.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+	rewrite(&manifest)
+
+	data, err = json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+// copyFile copies src over dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"querycraft/pkg/qcparser/types"
+	"testing"
+)
+
+// convertFixture writes a DJSON output and its manifest like a conversion
+// of source into outputPath would
+func convertFixture(t *testing.T, source, outputPath string) *types.ConvertResult {
+	t.Helper()
+	data := []byte("{\"id\":1}\n{\"id\":2}\n")
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &types.ConvertResult{
+		DJSONPath:    outputPath,
+		RowsWritten:  2,
+		BytesWritten: int64(len(data)),
+		Files:        []types.OutputFile{{Path: outputPath, Rows: 2, Bytes: int64(len(data))}},
+	}
+	manifest := types.Manifest{
+		Sources:     []types.SourceInfo{{Path: source}},
+		RowsWritten: result.RowsWritten,
+		Files:       result.Files,
+	}
+	encoded, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(types.ManifestPath(outputPath), encoded, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestLookupRewritesPaths(t *testing.T) {
+	dir := t.TempDir()
+	work := t.TempDir()
+
+	first := filepath.Join(work, "first.djson")
+	result := convertFixture(t, filepath.Join(work, "a.csv"), first)
+	if err := Store(dir, "key", filepath.Join(work, "a.csv"), result, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later conversion of an identical input to another output
+	second := filepath.Join(work, "sub", "second.djson")
+	os.MkdirAll(filepath.Dir(second), 0755)
+	source := filepath.Join(work, "b.csv")
+	hit, ok, err := Lookup(dir, "key", source, second)
+	if err != nil || !ok {
+		t.Fatalf("Lookup = %v, %v", ok, err)
+	}
+
+	if !hit.Cached {
+		t.Error("result is not marked cached")
+	}
+	if hit.DJSONPath != second {
+		t.Errorf("DJSONPath = %s, want %s", hit.DJSONPath, second)
+	}
+	if len(hit.Files) != 1 || hit.Files[0].Path != second || hit.Files[0].Rows != 2 {
+		t.Errorf("Files = %+v, want one file at %s", hit.Files, second)
+	}
+	if data, err := os.ReadFile(second); err != nil || len(data) != int(result.BytesWritten) {
+		t.Errorf("output not copied: %q, %v", data, err)
+	}
+
+	data, err := os.ReadFile(types.ManifestPath(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest types.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 1 || manifest.Files[0].Path != second {
+		t.Errorf("manifest files = %+v, want one file at %s", manifest.Files, second)
+	}
+	if len(manifest.Sources) != 1 || manifest.Sources[0].Path != source {
+		t.Errorf("manifest sources = %+v, want %s", manifest.Sources, source)
+	}
+
+	// The first output is left as it was
+	data, err = os.ReadFile(types.ManifestPath(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var original types.Manifest
+	if err := json.Unmarshal(data, &original); err != nil {
+		t.Fatal(err)
+	}
+	if len(original.Files) != 1 || original.Files[0].Path != first {
+		t.Errorf("first manifest files = %+v, want one file at %s", original.Files, first)
+	}
+}
+
+func TestLookupMiss(t *testing.T) {
+	_, ok, err := Lookup(t.TempDir(), "missing", "in.csv", filepath.Join(t.TempDir(), "out.djson"))
+	if ok || err != nil {
+		t.Errorf("Lookup of a missing key = %v, %v", ok, err)
+	}
+}
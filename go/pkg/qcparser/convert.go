@@ -1,26 +1,215 @@
 package qcparser
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"querycraft/pkg/qcparser/cache"
 	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/internal/dedupe"
+	"querycraft/pkg/qcparser/internal/expr"
 	"querycraft/pkg/qcparser/internal/reader"
+	"querycraft/pkg/qcparser/internal/sorter"
+	"querycraft/pkg/qcparser/internal/util"
 	"querycraft/pkg/qcparser/internal/writer"
 	"querycraft/pkg/qcparser/types"
 	"sync"
+	"time"
 )
 
 // Convert detects file format and converts it to DJSON for DuckDB
 func Convert(filePath string, outputPath string, opts *types.Options) (*types.ConvertResult, error) {
+	return ConvertContext(context.Background(), filePath, outputPath, opts, nil)
+}
+
+// ConvertContext is like Convert but stops when ctx is cancelled, removing
+// the partial output, and reports progress to the optional progress func
+func ConvertContext(ctx context.Context, filePath string, outputPath string, opts *types.Options, progress types.ProgressFunc) (*types.ConvertResult, error) {
+	started := time.Now()
+
+	// Reuse an earlier conversion of the same input with the same options;
+	// a failing cache never fails the conversion
+	var cacheKey string
+	if opts.CacheDir != "" && !writer.Sharded(opts) {
+		if key, err := cache.Key(filePath, opts); err == nil {
+			if result, ok, err := cache.Lookup(opts.CacheDir, key, filePath, outputPath); err == nil && ok {
+				return result, nil
+			}
+			cacheKey = key
+		}
+	}
+
 	// Step 1: Detect file format and structure
 	detected, err := detector.Detect(filePath, opts)
 	if err != nil {
 		return nil, fmt.Errorf("detection failed: %w", err)
 	}
+	pipeline, config, err := compileTransforms(detected, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Dedupe and sort compare the values before masking, which would make
+	// redacted keys equal and hashed ones unordered
+	keyColumns, err := dedupe.KeyColumns(config, opts)
+	if err != nil {
+		return nil, err
+	}
+	order, err := sorter.NewOrder(config, opts.SortBy)
+	if err != nil {
+		return nil, err
+	}
+	masker, config, err := compileMasks(config, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.CheckPlan(config, opts); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Hash the input while it is read, for the manifest
+	source, err := writer.NewSource(filePath, file)
+	if err != nil {
+		return nil, err
+	}
 
 	// Step 2: Read file (returns channels for streaming)
-	rowChan, errChan := reader.Read(filePath, detected)
+	input := util.NewProgressReader(ctx, source.Hash)
+	rowChan, errChan, stats := reader.ReadFrom(input, detected, opts)
+	wait := collectErrors(errChan)
+	rows, stop := util.TrackRows(rowChan, input, source.Info.Size(), progress)
+	rows, removed := dedupeRows(transformRows(rows, pipeline), keyColumns, opts)
+	rows, sorted := sortRows(rows, order, opts)
+	rows = maskRows(rows, masker)
+
+	// Step 3: Write DJSON file (consumes row channel)
+	result, err := writer.Write(rows, config, opts, outputPath)
+	stop()
+	if err != nil {
+		return nil, fmt.Errorf("write failed: %w", err)
+	}
+	if result.DuplicatesRemoved, err = removed(); err != nil {
+		removeOutput(outputPath, result)
+		return nil, fmt.Errorf("dedupe failed: %w", err)
+	}
+	if err := sorted(); err != nil {
+		removeOutput(outputPath, result)
+		return nil, fmt.Errorf("sort failed: %w", err)
+	}
+
+	// Add collected errors to result
+	result.Errors = wait()
+	result.RaggedRows = stats.Ragged
+
+	// The reader stopped early, so the output is incomplete
+	if ctx.Err() != nil {
+		removeOutput(outputPath, result)
+		return nil, ctx.Err()
+	}
+
+	// The manifest describes the final result, after the dedupe and sort checks
+	if err := writer.WriteManifest(outputPath, config, opts, []*writer.Source{source}, result, started); err != nil {
+		removeOutput(outputPath, result)
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+
+	if cacheKey != "" {
+		cache.Store(opts.CacheDir, cacheKey, filePath, result, opts.CacheMaxBytes)
+	}
+
+	return result, nil
+}
+
+// removeOutput deletes the files written by a failed conversion
+func removeOutput(outputPath string, result *types.ConvertResult) {
+	for _, file := range result.Files {
+		os.Remove(file.Path)
+	}
+	os.Remove(types.ManifestPath(outputPath))
+}
+
+// ConvertStream detects the format of a stream and writes it as DJSON to w.
+// The detection sample is replayed from memory, so r is never seeked.
+func ConvertStream(r io.Reader, w io.Writer, opts *types.Options) (*types.ConvertResult, error) {
+	// Step 1: Detect format from the head of the stream
+	detected, replay, err := detector.DetectReader(r, opts)
+	if err != nil {
+		return nil, fmt.Errorf("detection failed: %w", err)
+	}
+	pipeline, config, err := compileTransforms(detected, opts)
+	if err != nil {
+		return nil, err
+	}
+	keyColumns, err := dedupe.KeyColumns(config, opts)
+	if err != nil {
+		return nil, err
+	}
+	order, err := sorter.NewOrder(config, opts.SortBy)
+	if err != nil {
+		return nil, err
+	}
+	masker, config, err := compileMasks(config, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.CheckPlan(config, opts); err != nil {
+		return nil, err
+	}
+
+	// Step 2: Read the whole stream, starting with the replayed sample
+	rowChan, errChan, stats := reader.ReadFrom(replay, detected, opts)
+	wait := collectErrors(errChan)
+	rows, removed := dedupeRows(transformRows(rowChan, pipeline), keyColumns, opts)
+	rows, sorted := sortRows(rows, order, opts)
+	rows = maskRows(rows, masker)
+
+	// Step 3: Write DJSON (consumes row channel)
+	result, err := writer.WriteTo(rows, config, opts, w)
+	if err != nil {
+		return nil, fmt.Errorf("write failed: %w", err)
+	}
+	if result.DuplicatesRemoved, err = removed(); err != nil {
+		return nil, fmt.Errorf("dedupe failed: %w", err)
+	}
+	if err := sorted(); err != nil {
+		return nil, fmt.Errorf("sort failed: %w", err)
+	}
+
+	result.Errors = wait()
+	result.RaggedRows = stats.Ragged
+
+	return result, nil
+}
+
+// dedupeRows drops duplicate rows when key columns are given; the returned
+// function reports how many were removed once the rows are consumed
+func dedupeRows(rows <-chan map[string]string, keyColumns []string, opts *types.Options) (<-chan map[string]string, func() (int64, error)) {
+	if len(keyColumns) == 0 {
+		return rows, func() (int64, error) { return 0, nil }
+	}
+	out, stats := dedupe.Rows(rows, keyColumns, opts.DedupeKeep, opts.MemoryBytes, opts.TempDir)
+	return out, func() (int64, error) { return stats.Removed, stats.Err }
+}
+
+// sortRows orders the rows when there is an order; the returned function
+// reports a spill failure once the rows are consumed
+func sortRows(rows <-chan map[string]string, order *sorter.Order, opts *types.Options) (<-chan map[string]string, func() error) {
+	if order == nil {
+		return rows, func() error { return nil }
+	}
+	out, stats := sorter.Rows(rows, order, opts.MemoryBytes, opts.TempDir)
+	return out, func() error { return stats.Err }
+}
 
-	// Collect errors silently using WaitGroup
+// collectErrors gathers reader errors in the background; the returned
+// function waits for the error channel to close and returns them
+func collectErrors(errChan <-chan error) func() []string {
 	errors := make([]string, 0)
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -31,17 +220,14 @@ func Convert(filePath string, outputPath string, opts *types.Options) (*types.Co
 		}
 	}()
 
-	// Step 3: Write DJSON file (consumes row channel)
-	result, err := writer.Write(rowChan, detected, outputPath)
-	if err != nil {
-		return nil, fmt.Errorf("write failed: %w", err)
+	return func() []string {
+		wg.Wait()
+		return errors
 	}
+}
 
-	// Wait for error collection to complete
-	wg.Wait()
-
-	// Add collected errors to result
-	result.Errors = errors
-
-	return result, nil
+// CheckWhere reports syntax errors in a row filter expression before any
+// input is read; column names and types are checked once they are known
+func CheckWhere(where string) error {
+	return expr.Check(where)
 }
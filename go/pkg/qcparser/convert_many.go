@@ -0,0 +1,234 @@
+package qcparser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/internal/reader"
+	"querycraft/pkg/qcparser/internal/writer"
+	"querycraft/pkg/qcparser/types"
+	"strings"
+	"time"
+)
+
+// ConvertMany detects every input, reconciles their schemas by column name and
+// writes them as one dataset. When outputPath is a directory each input gets
+// its own DJSON with the shared schema, otherwise all rows go to outputPath.
+// Inputs whose schema cannot be reconciled are skipped and reported.
+func ConvertMany(inputs []string, outputPath string, opts *types.Options) (*types.DatasetResult, error) {
+	start := time.Now()
+	result := &types.DatasetResult{}
+
+	// Step 1: Detect every input and reconcile the schemas
+	set := newDataset()
+	detected := make(map[string]*types.DetectResponse, len(inputs))
+	var compatible []string
+	for _, path := range inputs {
+		d, err := detector.Detect(path, opts)
+		if err == nil {
+			err = set.add(path, d)
+		}
+		if err != nil {
+			result.Incompatible = append(result.Incompatible, types.IncompatibleInput{Path: path, Reason: err.Error()})
+			continue
+		}
+		detected[path] = d
+		compatible = append(compatible, path)
+	}
+	if len(compatible) == 0 {
+		return nil, errors.New("no input could be converted")
+	}
+	result.Columns = set.schema(opts.SourceColumn)
+	result.Issues = set.issues
+
+	// Transforms and masks apply to the reconciled columns
+	_, shared, err := compileTransforms(&types.DetectResponse{Columns: result.Columns}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, shared, err = compileMasks(shared, opts); err != nil {
+		return nil, err
+	}
+	result.Columns = shared.Columns
+
+	// Step 2: Convert each input against the shared schema
+	perInput := isDir(outputPath)
+	var out *os.File
+	if !perInput {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		out = file
+		result.DJSONPath = outputPath
+	}
+
+	var outputs []string
+	if perInput {
+		outputs = inputOutputPaths(outputPath, compatible)
+	}
+
+	var sources []*writer.Source
+	var castFailures map[string]int64
+	var errs []string
+	var ragged types.RaggedCounts
+	for i, path := range compatible {
+		inputOutput := ""
+		if perInput {
+			inputOutput = outputs[i]
+		}
+		config := set.inputConfig(detected[path], opts.SourceColumn)
+		written, source, err := convertInput(path, detected[path], config, set.renames(detected[path]), opts, inputOutput, out)
+		if err != nil {
+			return nil, fmt.Errorf("write failed for %s: %w", path, err)
+		}
+		sources = append(sources, source)
+		for name, n := range written.CastFailures {
+			if castFailures == nil {
+				castFailures = make(map[string]int64)
+			}
+			castFailures[name] += n
+		}
+
+		result.Inputs = append(result.Inputs, types.InputResult{
+			Path:         path,
+			DJSONPath:    written.DJSONPath,
+			RowsWritten:  written.RowsWritten,
+			BytesWritten: written.BytesWritten,
+			Errors:       written.Errors,
+			RaggedRows:   written.RaggedRows,
+		})
+		result.RowsWritten += written.RowsWritten
+		result.BytesWritten += written.BytesWritten
+		errs = append(errs, written.Errors...)
+		addRagged(&ragged, written.RaggedRows)
+	}
+
+	// The combined output is described with the reconciled schema
+	if !perInput {
+		_, config, err := compileTransforms(set.inputConfig(detected[compatible[0]], opts.SourceColumn), opts)
+		if err != nil {
+			return nil, err
+		}
+		if _, config, err = compileMasks(config, opts); err != nil {
+			return nil, err
+		}
+		written := &types.ConvertResult{
+			RowsWritten:  result.RowsWritten,
+			BytesWritten: result.BytesWritten,
+			CastFailures: castFailures,
+			Errors:       errs,
+			RaggedRows:   ragged,
+		}
+		if err := writer.WriteManifest(outputPath, config, opts, sources, written, start); err != nil {
+			return nil, fmt.Errorf("manifest: %w", err)
+		}
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// convertInput converts one input of a dataset, to its own DJSON at
+// inputOutput when set and to out otherwise
+func convertInput(path string, detected, config *types.DetectResponse, renames map[string]string, opts *types.Options, inputOutput string, out *os.File) (*types.ConvertResult, *writer.Source, error) {
+	start := time.Now()
+	pipeline, config, err := compileTransforms(config, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	masker, config, err := compileMasks(config, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writer.CheckPlan(config, opts); err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	source, err := writer.NewSource(path, file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rowChan, errChan, stats := reader.ReadFrom(source.Hash, detected, opts)
+	wait := collectErrors(errChan)
+	rows := maskRows(transformRows(sourceRows(rowChan, path, renames, opts.SourceColumn), pipeline), masker)
+
+	var written *types.ConvertResult
+	if inputOutput != "" {
+		written, err = writer.Write(rows, config, opts, inputOutput)
+	} else {
+		written, err = writer.WriteTo(rows, config, opts, out)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	written.Errors = wait()
+	written.RaggedRows = stats.Ragged
+
+	if inputOutput != "" {
+		if err := writer.WriteManifest(inputOutput, config, opts, []*writer.Source{source}, written, start); err != nil {
+			return nil, nil, fmt.Errorf("manifest: %w", err)
+		}
+	}
+	return written, source, nil
+}
+
+// sourceRows renames matched columns to their dataset names and tags each row with its input path
+func sourceRows(rowChan <-chan map[string]string, path string, renames map[string]string, sourceColumn string) <-chan map[string]string {
+	if len(renames) == 0 && sourceColumn == "" {
+		return rowChan
+	}
+
+	out := make(chan map[string]string, cap(rowChan))
+	go func() {
+		defer close(out)
+		for row := range rowChan {
+			for from, to := range renames {
+				if value, ok := row[from]; ok {
+					delete(row, from)
+					row[to] = value
+				}
+			}
+			if sourceColumn != "" {
+				row[sourceColumn] = path
+			}
+			out <- row
+		}
+	}()
+	return out
+}
+
+// inputOutputPaths names the DJSON of each input inside the output directory
+// after its file name, numbering the inputs whose names collide, like
+// a/data.csv and b/data.csv
+func inputOutputPaths(dir string, inputs []string) []string {
+	paths := make([]string, len(inputs))
+	used := make(map[string]bool, len(inputs))
+	for i, input := range inputs {
+		base := filepath.Base(input)
+		stem := strings.TrimSuffix(base, filepath.Ext(base))
+		name := stem + ".djson"
+		// Names differing in case collide on case-insensitive file systems
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s-%d.djson", stem, n)
+		}
+		used[strings.ToLower(name)] = true
+		paths[i] = filepath.Join(dir, name)
+	}
+	return paths
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
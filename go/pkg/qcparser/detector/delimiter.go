@@ -1,8 +1,10 @@
 package detector
 
 import (
+	"fmt"
 	"math"
 	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
 	"sort"
 	"strings"
 )
@@ -124,8 +126,20 @@ func getDelimiterStatus(analysis DelimiterAnalysis) DelimStatus {
 }
 
 // meetsConstraints checks if delimiter status meets minimum requirements
-func meetsConstraints(s DelimStatus) bool {
-	return s.ModeColumns >= 2 && s.ModeCoverage >= 0.80
+func meetsConstraints(s DelimStatus, h types.Heuristics) bool {
+	return len(constraintFailures(s, h)) == 0
+}
+
+// constraintFailures lists the minimum requirements a delimiter status fails
+func constraintFailures(s DelimStatus, h types.Heuristics) []string {
+	var failures []string
+	if s.ModeColumns < 2 {
+		failures = append(failures, fmt.Sprintf("mode column count %d is below 2", s.ModeColumns))
+	}
+	if s.ModeCoverage < h.MinModeCoverage {
+		failures = append(failures, fmt.Sprintf("mode coverage %.2f is below %.2f", s.ModeCoverage, h.MinModeCoverage))
+	}
+	return failures
 }
 
 // computeScore calculates weighted score for delimiter
@@ -149,7 +163,7 @@ func compare(a, b CandidateResult) bool {
 }
 
 // getWinners selects the best delimiter candidates
-func getWinners(candidates []CandidateResult) Decision {
+func getWinners(candidates []CandidateResult, h types.Heuristics) Decision {
 	passed := make([]CandidateResult, 0, len(candidates))
 
 	for _, c := range candidates {
@@ -169,7 +183,7 @@ func getWinners(candidates []CandidateResult) Decision {
 		if len(passed) >= 2 {
 			decision.RunnerUp = passed[1]
 			decision.AmbiguityEpsilon = math.Abs(decision.Winner.Score - decision.RunnerUp.Score)
-			decision.IsAmbiguous = decision.AmbiguityEpsilon < 0.05
+			decision.IsAmbiguous = decision.AmbiguityEpsilon < h.AmbiguityEpsilon
 		}
 		return decision
 	}
@@ -185,24 +199,40 @@ func getWinners(candidates []CandidateResult) Decision {
 }
 
 // getCSVDelimiter detects the best CSV delimiter from candidates
-func getCSVDelimiter(lines []string, delimiters []rune) []CandidateResult {
+func getCSVDelimiter(lines []string, delimiters []rune, h types.Heuristics) []CandidateResult {
+	return bestCandidates(scoreDelimiters(lines, delimiters, h), h)
+}
+
+// scoreDelimiters scores every delimiter candidate in the given order
+func scoreDelimiters(lines []string, delimiters []rune, h types.Heuristics) []CandidateResult {
 	candidates := make([]CandidateResult, 0, len(delimiters))
 	for _, d := range delimiters {
-		analysis := analyzeDelimiter(lines, d)
-		status := getDelimiterStatus(analysis)
-		candidates = append(candidates, CandidateResult{
-			Delimiter: d,
-			Status:    status,
-			Score:     computeScore(status, DefaultScoreWeights()),
-			Pass:      meetsConstraints(status),
-		})
+		candidates = append(candidates, scoreDelimiter(lines, d, h))
 	}
+	return candidates
+}
 
-	winners := getWinners(candidates)
+// bestCandidates returns the winner and runner-up of the scored candidates
+func bestCandidates(scored []CandidateResult, h types.Heuristics) []CandidateResult {
+	// getWinners may reorder its input
+	candidates := append([]CandidateResult(nil), scored...)
+	winners := getWinners(candidates, h)
 
 	return []CandidateResult{winners.Winner, winners.RunnerUp}
 }
 
+// scoreDelimiter analyzes and scores a single delimiter candidate
+func scoreDelimiter(lines []string, delimiter rune, h types.Heuristics) CandidateResult {
+	analysis := analyzeDelimiter(lines, delimiter)
+	status := getDelimiterStatus(analysis)
+	return CandidateResult{
+		Delimiter: delimiter,
+		Status:    status,
+		Score:     computeScore(status, scoreWeights(h)),
+		Pass:      meetsConstraints(status, h),
+	}
+}
+
 // splitLineFields parses a CSV line into fields
 func SplitLineFields(line string, delim rune) (fields []string, invalid bool) {
 	const dq = '"'
@@ -239,3 +269,9 @@ func SplitLineFields(line string, delim rune) (fields []string, invalid bool) {
 
 	return fields, invalid
 }
+
+// effectiveHeuristics returns the default detection heuristics with the
+// fields set by opts replaced
+func effectiveHeuristics(opts *types.Options) types.Heuristics {
+	return opts.Heuristics.Apply(types.DefaultHeuristics())
+}
@@ -13,12 +13,34 @@ import (
 )
 
 // detectCSV performs CSV format detection and analysis
-func detectCSV(lines []string, bytesRead int64, opts *types.Options, start time.Time) (*types.DetectResponse, error) {
+func detectCSV(lines []string, tail *TailSample, complete bool, bytesRead int64, opts *types.Options, start time.Time) (*types.DetectResponse, error) {
 	var issues []types.Issue
+	heuristics := effectiveHeuristics(opts)
+
+	// Locate the tabular block, skipping any preamble before it
+	skipRows := opts.SkipRows
+	if skipRows < 0 {
+		skipRows = findPreamble(lines, opts.Delimiters, heuristics)
+	}
+	skipRows = util.Min(skipRows, len(lines))
+	metadata := preambleLines(lines[:skipRows])
+	table := lines[skipRows:]
+
+	if len(table) == 0 {
+		return nil, fmt.Errorf("no data found after skipping %d rows", skipRows)
+	}
+
+	if skipRows > 0 {
+		issues = append(issues, types.Issue{
+			Code:    "PREAMBLE_SKIPPED",
+			Message: fmt.Sprintf("Skipped %d lines before the table", skipRows),
+		})
+	}
 
 	// Get delimiter candidates
-	candidates := getCSVDelimiter(lines, opts.Delimiters)
-	decision := getWinners(candidates)
+	scored := scoreDelimiters(table, opts.Delimiters, heuristics)
+	candidates := bestCandidates(scored, heuristics)
+	decision := getWinners(candidates, heuristics)
 
 	if len(candidates) == 0 {
 		return nil, errors.New("no valid delimiter found")
@@ -35,18 +57,56 @@ func detectCSV(lines []string, bytesRead int64, opts *types.Options, start time.
 	}
 
 	// Flag high invalid rate
-	if winner.Status.InvalidRate > 0.1 {
+	if winner.Status.InvalidRate > heuristics.MaxInvalidRate {
 		issues = append(issues, types.Issue{
 			Code:    "HIGH_INVALID_RATE",
-			Message: "More than 10% of lines have invalid formatting",
+			Message: fmt.Sprintf("More than %g%% of lines have invalid formatting", heuristics.MaxInvalidRate*100),
 		})
 	}
 
 	// Infer column types
-	cellTypes := getCellsTypes(lines, winner)
+	cellTypes := getCellsTypes(table, winner, opts.Locale)
+
+	// Find trailing summary or footer rows, in the tail sample for large files
+	var footerRows int
+	var footerLines []int
+	var footer []string
+	if tail != nil {
+		footerRows = findFooter(tail.Lines, winner, cellTypes)
+		if footerRows > 0 {
+			firstLine, err := tail.FirstLine()
+			if err != nil {
+				return nil, err
+			}
+			footerLines, footer = footerLineNumbers(tail.Lines, footerRows, firstLine)
+		}
+
+		// A sample reaching the end of the input holds the footer too
+		if footerRows > 0 && endsWith(table, tail.Lines[len(tail.Lines)-footerRows:]) {
+			table = table[:len(table)-footerRows]
+			cellTypes = getCellsTypes(table, winner, opts.Locale)
+		}
+	} else if complete {
+		footerRows = findFooter(table, winner, cellTypes)
+		footerLines, footer = footerLineNumbers(table, footerRows, skipRows+1)
+
+		// Re-infer types without the footer rows
+		if footerRows > 0 {
+			table = table[:len(table)-footerRows]
+			cellTypes = getCellsTypes(table, winner, opts.Locale)
+		}
+	}
+
+	if footerRows > 0 {
+		issues = append(issues, types.Issue{
+			Code:    "FOOTER_ROWS",
+			Message: fmt.Sprintf("Excluded %d trailing summary or footer rows", len(footerLines)),
+			Lines:   footerLines,
+		})
+	}
 
 	// Detect headers
-	hasHeader, headerNames := hasHeaders(lines, winner, cellTypes)
+	hasHeader, headerNames := hasHeaders(table, winner, cellTypes)
 
 	// Build columns
 	columns := make([]types.Column, winner.Status.ModeColumns)
@@ -62,18 +122,49 @@ func detectCSV(lines []string, bytesRead int64, opts *types.Options, start time.
 		}
 
 		colType := "TEXT"
+		colFormat := ""
+		colLocale := ""
 		if i < len(cellTypes) {
 			colType = inferredKindToColumnType(cellTypes[i].Kind)
+			colFormat = cellTypes[i].Format
+			colLocale = cellTypes[i].Locale
+			if cellTypes[i].AmbiguousDates {
+				issues = append(issues, types.Issue{
+					Code:    "AMBIGUOUS_DATE_FORMAT",
+					Message: fmt.Sprintf("Dates in column %s read both day first and month first; pin the format with a schema", colName),
+					Column:  colName,
+				})
+			}
 		}
 
 		columns[i] = types.Column{
-			Name: colName,
-			Type: colType,
+			Name:   colName,
+			Type:   colType,
+			Format: colFormat,
+			Locale: colLocale,
 		}
 	}
 
+	// Flag columns holding personal data and find what the values stand for
+	values := splitColumns(table, winner)
+	if hasHeader {
+		for i := range values {
+			values[i] = values[i][min(1, len(values[i])):]
+		}
+	}
+	issues = append(issues, piiIssues(columns, values, heuristics.MinPIIShare)...)
+	inferSemanticTypes(columns, values, heuristics.MinSemanticShare)
+
+	// Surplus fields of ragged rows get their own list column
+	if opts.RaggedRows == types.RaggedExtra {
+		columns = append(columns, types.Column{
+			Name: types.ExtraColumn,
+			Type: "TEXT[]",
+		})
+	}
+
 	// Generate preview
-	preview := generatePreview(lines, winner, columns, hasHeader, opts.MaxPreviewRows)
+	preview := generatePreview(table, winner, columns, hasHeader, opts.MaxPreviewRows, opts.RaggedRows)
 
 	// Detect comment prefix
 	var commentPrefix *string
@@ -105,23 +196,34 @@ func detectCSV(lines []string, bytesRead int64, opts *types.Options, start time.
 		ConfidencePct: coveragePct,
 	}
 
+	// Keep the evidence behind the decisions when asked to
+	var explanation *types.Explanation
+	if opts.Explain {
+		explanation = explainCSV(table, scored, decision, cellTypes, hasHeader, heuristics)
+	}
+
 	return &types.DetectResponse{
 		Format:     "csv",
 		Encoding:   "utf-8",
 		Delimiter:  delimiterInfo,
 		Comment:    commentPrefix,
 		HasHeader:  hasHeader,
+		SkipRows:   skipRows,
+		FooterRows: footerRows,
 		FieldCount: winner.Status.ModeColumns,
 		TrimFields: true,
 		Columns:    columns,
 		Preview:    preview,
 		Confidence: confidence,
 		Issues:     issues,
+		Metadata:   metadata,
+		Footer:     footer,
 		Sampled: types.SampledMeta{
 			Lines:      len(lines),
 			Bytes:      bytesRead,
 			DurationMs: util.DurationMs(start),
 		},
+		Explain:    explanation,
 		DurationMs: util.DurationMs(start),
 	}, nil
 }
@@ -212,6 +314,18 @@ func detectJSON(lines []string, bytesRead int64, format string, opts *types.Opti
 		})
 	}
 
+	// Flag columns holding personal data and find what the values stand for,
+	// from the preview rows
+	values := make([][]string, len(columns))
+	for i, col := range columns {
+		for _, row := range previewData {
+			values[i] = append(values[i], row[col.Name])
+		}
+	}
+	heuristics := effectiveHeuristics(opts)
+	issues = append(issues, piiIssues(columns, values, heuristics.MinPIIShare)...)
+	inferSemanticTypes(columns, values, heuristics.MinSemanticShare)
+
 	preview = types.Preview{
 		Rows:        len(previewData),
 		Data:        previewData,
@@ -1,7 +1,9 @@
 package detector
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"os"
 	"querycraft/pkg/qcparser/internal/util"
 	"querycraft/pkg/qcparser/types"
@@ -19,15 +21,112 @@ func Detect(filePath string, opts *types.Options) (*types.DetectResponse, error)
 	}
 	defer file.Close()
 
-	// Read sample lines
-	lines, bytesRead, err := util.GetLines(file, opts.SampleBytes, opts.MaxLineBytes)
+	info, err := file.Stat()
 	if err != nil {
 		return nil, err
 	}
 
+	// Read sample lines with the configured strategy
+	lines, regions, err := util.Sample(file, info.Size(), opts.Sampling, opts.SampleRegions, opts.SampleBytes, opts.MaxLineBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := sampleFormat(lines, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// A JSON document cannot be parsed from pieces, keep its head only
+	if format == "json" && len(regions) > 1 {
+		lines = lines[:regions[0].Lines]
+		regions = regions[:1]
+	}
+
+	var bytesRead int64
+	for _, region := range regions {
+		bytesRead += region.Bytes
+	}
+
+	var result *types.DetectResponse
+	switch {
+	case opts.Schema != nil:
+		// A pinned schema bypasses inference
+		result, err = bindSchema(opts.Schema, lines, bytesRead, opts, start)
+	case format == "json", format == "jsonl":
+		result, err = detectJSON(lines, bytesRead, format, opts, start)
+	case format == "csv":
+		tail, tailErr := readTail(file, opts.SampleBytes)
+		if tailErr != nil {
+			return nil, tailErr
+		}
+		result, err = detectCSV(lines, tail, tail == nil, bytesRead, opts, start)
+	default:
+		err = errors.New("unknown format")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Sampled.Strategy = regionsStrategy(opts.Sampling, regions)
+	result.Sampled.Regions = regions
+	return result, nil
+}
+
+// regionsStrategy returns the strategy that produced the regions; small
+// inputs are always read whole from the head
+func regionsStrategy(strategy string, regions []types.SampleRegion) string {
+	if len(regions) <= 1 {
+		return types.SampleHead
+	}
+	return strategy
+}
+
+// DetectReader analyzes a stream and returns format detection results.
+// The returned reader replays the consumed sample followed by the rest of
+// the stream, so the caller can read the input from the start without seeking.
+// Footer rows are only found when the whole stream fits in the sample.
+func DetectReader(r io.Reader, opts *types.Options) (*types.DetectResponse, io.Reader, error) {
+	start := time.Now()
+
+	// Keep a copy of everything read so it can be replayed
+	var consumed bytes.Buffer
+	lines, bytesRead, err := util.GetLines(io.TeeReader(r, &consumed), opts.SampleBytes, opts.MaxLineBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	replay := io.MultiReader(&consumed, r)
+
+	format, err := sampleFormat(lines, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var result *types.DetectResponse
+	switch {
+	case opts.Schema != nil:
+		// A pinned schema bypasses inference
+		result, err = bindSchema(opts.Schema, lines, bytesRead, opts, start)
+	case format == "json", format == "jsonl":
+		result, err = detectJSON(lines, bytesRead, format, opts, start)
+	case format == "csv":
+		complete := opts.SampleBytes <= 0 || bytesRead < opts.SampleBytes
+		result, err = detectCSV(lines, nil, complete, bytesRead, opts, start)
+	default:
+		err = errors.New("unknown format")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, replay, nil
+}
+
+// sampleFormat validates the sample lines and detects their format
+func sampleFormat(lines []string, opts *types.Options) (string, error) {
 	// Validate UTF-8
 	if !util.IsUTF8(lines) {
-		return nil, errors.New("file is not valid UTF-8")
+		return "", errors.New("file is not valid UTF-8")
 	}
 
 	// Trim BOM if present
@@ -35,13 +134,32 @@ func Detect(filePath string, opts *types.Options) (*types.DetectResponse, error)
 
 	// Detect format (CSV, JSON, or JSONL)
 	format, _ := util.DataFormat(lines, opts.MaxPreviewRows)
+	return format, nil
+}
+
+// readTail samples the end of a file that is larger than the head sample,
+// so footer rows can be found. It returns nil when the head covers the file.
+func readTail(file *os.File, sampleBytes int64) (*TailSample, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if sampleBytes <= 0 || info.Size() <= sampleBytes {
+		return nil, nil
+	}
+
+	lines, err := util.GetTailLines(file, info.Size(), tailSampleBytes)
+	if err != nil {
+		return nil, err
+	}
 
-	switch format {
-	case "json", "jsonl":
-		return detectJSON(lines, bytesRead, format, opts, start)
-	case "csv":
-		return detectCSV(lines, bytesRead, opts, start)
+	// Lines are counted only when footer rows must be reported by line number
+	countLines := func() (int, error) {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		return util.CountLines(file)
 	}
 
-	return nil, errors.New("unknown format")
+	return &TailSample{Lines: lines, countLines: countLines}, nil
 }
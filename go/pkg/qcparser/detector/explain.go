@@ -0,0 +1,122 @@
+package detector
+
+import (
+	"fmt"
+	"math"
+	"querycraft/pkg/qcparser/types"
+	"strings"
+)
+
+// explainCSV collects the evidence behind a CSV detection
+func explainCSV(table []string, scored []CandidateResult, decision Decision, cellTypes []CellInference, hasHeader bool, h types.Heuristics) *types.Explanation {
+	explanation := &types.Explanation{
+		Candidates: make([]types.DelimiterCandidate, len(scored)),
+		Decision: types.DelimiterDecision{
+			Winner:         string(decision.Winner.Delimiter),
+			ScoreGap:       round4(decision.AmbiguityEpsilon),
+			Ambiguous:      decision.IsAmbiguous,
+			CandidateCount: len(scored),
+		},
+		Header:     explainHeader(table, decision.Winner, cellTypes, hasHeader),
+		Columns:    explainColumns(table, decision.Winner, cellTypes),
+		Heuristics: h,
+	}
+
+	if decision.RunnerUp.Delimiter != 0 {
+		explanation.Decision.RunnerUp = string(decision.RunnerUp.Delimiter)
+	}
+
+	for i, c := range scored {
+		if c.Pass {
+			explanation.Decision.EligibleCount++
+		}
+		reasons := constraintFailures(c.Status, h)
+		if c.Pass {
+			reasons = []string{fmt.Sprintf("%d columns on %.2f of valid lines", c.Status.ModeColumns, c.Status.ModeCoverage)}
+		}
+		explanation.Candidates[i] = types.DelimiterCandidate{
+			Delimiter:         string(c.Delimiter),
+			ModeColumns:       c.Status.ModeColumns,
+			ModeCoverage:      round4(c.Status.ModeCoverage),
+			FieldCountStdDev:  round4(c.Status.FieldCountStdDev),
+			InvalidRate:       round4(c.Status.InvalidRate),
+			QuoteAffectedRate: round4(c.Status.QuoteAffectedRate),
+			TotalLines:        c.Status.TotalLines,
+			ValidLines:        c.Status.ValidCount,
+			Score:             round4(c.Score),
+			Pass:              c.Pass,
+			Reasons:           reasons,
+		}
+	}
+
+	return explanation
+}
+
+// explainHeader compares each cell of the candidate header row with its column type
+func explainHeader(table []string, delimiter CandidateResult, cellTypes []CellInference, hasHeader bool) types.HeaderEvidence {
+	evidence := types.HeaderEvidence{HasHeader: hasHeader}
+	candidate := headerCandidate(table, delimiter)
+
+	var differing []string
+	for i, cell := range candidate {
+		cellKind := inferCellType(cell, cellTypes[i].Locale).Kind
+		headerCell := types.HeaderCell{
+			Value:      cell,
+			Type:       kindName(cellKind),
+			ColumnType: kindName(cellTypes[i].Kind),
+			Differs:    cellKind != cellTypes[i].Kind,
+		}
+		if headerCell.Differs {
+			differing = append(differing, fmt.Sprintf("%q", strings.TrimSpace(cell)))
+		}
+		evidence.Cells = append(evidence.Cells, headerCell)
+	}
+
+	switch {
+	case candidate == nil:
+		evidence.Reason = "no line matches the detected field count"
+	case len(differing) > 0:
+		evidence.Reason = fmt.Sprintf("first row cells %s do not match their column types", strings.Join(differing, ", "))
+	default:
+		evidence.Reason = "every first row cell matches its column type"
+	}
+
+	return evidence
+}
+
+// explainColumns counts the type votes of every column's cells
+func explainColumns(table []string, delimiter CandidateResult, cellTypes []CellInference) []types.ColumnVotes {
+	columns := splitColumns(table, delimiter)
+	votes := make([]types.ColumnVotes, len(columns))
+
+	for i, column := range columns {
+		votes[i] = types.ColumnVotes{
+			Index:  i,
+			Type:   inferredKindToColumnType(cellTypes[i].Kind),
+			Format: cellTypes[i].Format,
+			Votes:  make(map[string]int),
+		}
+		for _, cell := range column {
+			kind := inferCellType(cell, cellTypes[i].Locale).Kind
+			if kind == KindEmpty {
+				votes[i].Nulls++
+				continue
+			}
+			votes[i].Votes[inferredKindToColumnType(kind)]++
+		}
+	}
+
+	return votes
+}
+
+// kindName returns the column type of an inferred kind, or NULL for empty cells
+func kindName(kind InferredKind) string {
+	if kind == KindEmpty {
+		return "NULL"
+	}
+	return inferredKindToColumnType(kind)
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -0,0 +1,155 @@
+package detector
+
+import (
+	"querycraft/pkg/qcparser/internal/util"
+	"strings"
+	"unicode"
+)
+
+// maxFooterRows caps how many trailing rows may be treated as footer
+const maxFooterRows = 10
+
+// tailSampleBytes is the size of the sample taken from the end of large files
+const tailSampleBytes = 64 << 10 // 64KB
+
+// summaryWords are leading cell values of summary rows; the cell holds the
+// word alone, optionally followed by a colon or a number, so a data value
+// like "Count Dracula" is no label
+var summaryWords = []string{
+	"total", "grand total", "subtotal", "sum", "count", "average", "avg",
+}
+
+// footerPrefixes start the leading cell of footer rows, as whole words
+var footerPrefixes = []string{
+	"generated", "report generated", "exported", "end of",
+}
+
+// findFooter returns the number of trailing lines that hold summary or
+// footer rows, counted from the end of lines. Blank and comment lines
+// after the last footer row are included in the count.
+func findFooter(lines []string, delimiter CandidateResult, cellTypes []CellInference) int {
+	footer := 0
+	found := 0
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		if util.IsComment(line) {
+			continue
+		}
+
+		// Stop at the first row that belongs to the table
+		if !isFooterRow(line, delimiter, cellTypes) {
+			return footer
+		}
+
+		found++
+		footer = len(lines) - i
+		if found > maxFooterRows {
+			break
+		}
+	}
+
+	// No data row found before the footer, don't trust the guess
+	return 0
+}
+
+// footerLineNumbers returns the 1-based line numbers of the non-blank footer lines
+func footerLineNumbers(lines []string, footer int, firstLine int) ([]int, []string) {
+	numbers := make([]int, 0, footer)
+	text := make([]string, 0, footer)
+	for i := len(lines) - footer; i < len(lines); i++ {
+		if util.IsComment(lines[i]) {
+			continue
+		}
+		numbers = append(numbers, firstLine+i)
+		text = append(text, strings.TrimSpace(lines[i]))
+	}
+	return numbers, text
+}
+
+// isFooterRow checks if a line is a summary or footer row: a leading label
+// like "Total" whose other cells are empty or numeric aggregates. A field
+// count or type mismatch alone is no evidence, ragged data rows are left to
+// the ragged row policy and mistyped values to the writer.
+func isFooterRow(line string, delimiter CandidateResult, cellTypes []CellInference) bool {
+	fields, invalid := SplitLineFields(line, delimiter.Delimiter)
+	if invalid {
+		return false
+	}
+
+	label := -1
+	for i, field := range fields {
+		trimmed := strings.TrimSpace(field)
+		if trimmed == "" {
+			continue
+		}
+		if label < 0 {
+			if !isSummaryLabel(trimmed) {
+				return false
+			}
+			label = i
+			continue
+		}
+		if !isAggregate(trimmed, cellLocale(cellTypes, i)) {
+			return false
+		}
+	}
+	return label >= 0
+}
+
+// isSummaryLabel checks for a leading cell value typical of summary and
+// footer rows: a summary word like "Total" or "Count: 12", or a footer
+// phrase like "Generated on" matched as whole words so "generators" is none
+func isSummaryLabel(cell string) bool {
+	lower := strings.ToLower(cell)
+	for _, word := range summaryWords {
+		rest, ok := strings.CutPrefix(lower, word)
+		if !ok {
+			continue
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ":"))
+		if rest == "" || isAggregate(rest, "") {
+			return true
+		}
+	}
+	for _, prefix := range footerPrefixes {
+		rest, ok := strings.CutPrefix(lower, prefix)
+		if ok && (rest == "" || !unicode.IsLetter([]rune(rest)[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAggregate checks for a numeric summary value, such as a sum or a share
+func isAggregate(cell, locale string) bool {
+	kind := inferCellType(cell, locale).Kind
+	if kind == KindInt || kind == KindFloat {
+		return true
+	}
+	_, isAmount := ParseAmount(cell)
+	_, isPercentage := ParsePercentage(cell)
+	return isAmount || isPercentage
+}
+
+// cellLocale returns the locale of the column at i, or "" past the columns
+func cellLocale(cellTypes []CellInference, i int) string {
+	if i < len(cellTypes) {
+		return cellTypes[i].Locale
+	}
+	return ""
+}
+
+// endsWith checks if lines end with the suffix lines
+func endsWith(lines, suffix []string) bool {
+	if len(suffix) > len(lines) {
+		return false
+	}
+	offset := len(lines) - len(suffix)
+	for i, line := range suffix {
+		if lines[offset+i] != line {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package detector
+
+import "testing"
+
+func TestIsSummaryLabel(t *testing.T) {
+	tests := []struct {
+		cell string
+		want bool
+	}{
+		{"Total", true},
+		{"TOTAL:", true},
+		{"Grand Total", true},
+		{"Count: 12", true},
+		{"Sum 1,234.50", true},
+		{"avg 12%", true},
+		{"Generated on 2024-01-01", true},
+		{"End of report", true},
+		{"Count Dracula", false},
+		{"Sum of parts", false},
+		{"Totally", false},
+		{"summer", false},
+		{"Total revenue", false},
+		{"Generators", false},
+	}
+	for _, tt := range tests {
+		if got := isSummaryLabel(tt.cell); got != tt.want {
+			t.Errorf("isSummaryLabel(%q) = %v, want %v", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestFindFooter(t *testing.T) {
+	comma := CandidateResult{Delimiter: ','}
+	cellTypes := []CellInference{{Kind: KindText}, {Kind: KindInt}, {Kind: KindFloat}}
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  int
+	}{
+		{
+			name:  "total row",
+			lines: []string{"a,1,1.5", "b,2,2.5", "Total,3,4.0"},
+			want:  1,
+		},
+		{
+			name:  "total and generated rows with a blank line",
+			lines: []string{"a,1,1.5", "b,2,2.5", "Total:,3,4.0", "Generated 2024-01-01,,", ""},
+			want:  3,
+		},
+		{
+			name:  "data row starting with a summary word",
+			lines: []string{"a,1,1.5", "b,2,2.5", "Count Dracula,,"},
+			want:  0,
+		},
+		{
+			name:  "only summary rows",
+			lines: []string{"Total,3,4.0"},
+			want:  0,
+		},
+	}
+	for _, tt := range tests {
+		if got := findFooter(tt.lines, comma, cellTypes); got != tt.want {
+			t.Errorf("%s: findFooter = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -45,13 +45,14 @@ func inferJSONType(val interface{}) string {
 }
 
 // generatePreview creates preview data from CSV lines
-func generatePreview(lines []string, delimiter CandidateResult, columns []types.Column, hasHeader bool, maxRows int) types.Preview {
+func generatePreview(lines []string, delimiter CandidateResult, columns []types.Column, hasHeader bool, maxRows int, raggedPolicy string) types.Preview {
 	preview := types.Preview{
 		Data: make([]map[string]string, 0, maxRows),
 	}
 
 	skippedHeader := false
 	invalidCount := 0
+	var ragged types.RaggedCounts
 
 	for _, line := range lines {
 		if util.IsComment(line) {
@@ -60,18 +61,24 @@ func generatePreview(lines []string, delimiter CandidateResult, columns []types.
 
 		fields, invalid := SplitLineFields(line, delimiter.Delimiter)
 
-		// Skip invalid rows or rows with wrong field count
-		if invalid || len(fields) != delimiter.Status.ModeColumns {
+		// Skip invalid rows or rows rejected by the ragged row policy
+		if invalid {
 			invalidCount++
 			continue
 		}
 
-		// Skip header row if present (only after validation)
+		// Skip header row if present, it is not subject to the ragged row policy
 		if hasHeader && !skippedHeader {
 			skippedHeader = true
 			continue
 		}
 
+		fields, extra, ok := FitFields(fields, delimiter.Status.ModeColumns, raggedPolicy, &ragged)
+		if !ok {
+			invalidCount++
+			continue
+		}
+
 		if len(preview.Data) >= maxRows {
 			break
 		}
@@ -83,6 +90,9 @@ func generatePreview(lines []string, delimiter CandidateResult, columns []types.
 				row[columns[i].Name] = strings.TrimSpace(field)
 			}
 		}
+		if len(extra) > 0 {
+			row[types.ExtraColumn] = EncodeExtra(extra)
+		}
 		preview.Data = append(preview.Data, row)
 	}
 
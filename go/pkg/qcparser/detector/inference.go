@@ -1,7 +1,13 @@
 package detector
 
 import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
 	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -23,10 +29,14 @@ const (
 type CellInference struct {
 	Kind       InferredKind
 	Confidence float64 // 0..1
+	Format     string  // strftime format shared by all cells of a date column
+	Locale     string  // Separators and month names of a numeric or date column
+
+	AmbiguousDates bool // Date cells read both day first and month first; Format is empty
 }
 
-// inferCellType infers the data type of a cell value
-func inferCellType(s string) CellInference {
+// inferCellType infers the data type of a cell value written in locale
+func inferCellType(s, locale string) CellInference {
 	t := strings.TrimSpace(s)
 	if t == "" || isNullToken(t) {
 		return CellInference{Kind: KindEmpty, Confidence: 1.0}
@@ -39,29 +49,55 @@ func inferCellType(s string) CellInference {
 		return CellInference{Kind: KindBool, Confidence: 0.90}
 	}
 
-	if ok := parseIntRelaxed(t); ok {
+	if ok := parseIntRelaxed(t, locale); ok {
 		return CellInference{Kind: KindInt, Confidence: 0.98}
 	}
 
-	if ok := parseFloatRelaxed(t); ok {
+	if ok := parseFloatRelaxed(t, locale); ok {
 		return CellInference{Kind: KindFloat, Confidence: 0.93}
 	}
 
-	if ok := parseDateAny(t); ok {
+	if ok := parseDateAny(t, locale); ok {
 		return CellInference{Kind: KindDate, Confidence: 0.92}
 	}
 
 	return CellInference{Kind: KindText, Confidence: 0.60}
 }
 
+// IsNullValue checks if a cell is empty or holds a null token
+func IsNullValue(s string) bool {
+	t := strings.TrimSpace(s)
+	return t == "" || isNullToken(t)
+}
+
+// IsBoolValue checks if a cell holds a boolean token
+func IsBoolValue(s string) bool {
+	return isBoolToken(strings.TrimSpace(s))
+}
+
+// IsDateValue checks if a cell written in locale parses with one of the
+// known date layouts
+func IsDateValue(s, locale string) bool {
+	return parseDateAny(strings.TrimSpace(s), locale)
+}
+
+// nullTokens are the lowercase cell values treated as null
+var nullTokens = []string{"null", "nil", "na", "n/a", "none", "-"}
+
+// NullTokens returns the lowercase cell values treated as null
+func NullTokens() []string {
+	return append([]string(nil), nullTokens...)
+}
+
 // isNullToken checks if a string represents a null value
 func isNullToken(t string) bool {
-	switch strings.ToLower(t) {
-	case "null", "nil", "na", "n/a", "none", "-":
-		return true
-	default:
-		return false
+	lower := strings.ToLower(t)
+	for _, token := range nullTokens {
+		if lower == token {
+			return true
+		}
 	}
+	return false
 }
 
 // isBoolToken checks if a string represents a boolean value
@@ -79,81 +115,306 @@ func eqFold(a, b string) bool {
 	return strings.EqualFold(a, b)
 }
 
-// parseIntRelaxed tries to parse a string as an integer
-func parseIntRelaxed(t string) bool {
-	clean := removeThousands(t)
-
-	if strings.ContainsAny(clean, ".eE") {
-		return false
-	}
-	_, err := strconv.ParseInt(clean, 10, 64)
-	return err == nil
+// parseIntRelaxed tries to parse a string as an integer written in locale
+func parseIntRelaxed(t, locale string) bool {
+	_, ok := ParseInteger(t, locale)
+	return ok
 }
 
-// parseFloatRelaxed tries to parse a string as a float
-func parseFloatRelaxed(t string) bool {
-	clean := removeThousands(t)
-	_, err := strconv.ParseFloat(clean, 64)
-	return err == nil
+// parseFloatRelaxed tries to parse a string as a float written in locale
+func parseFloatRelaxed(t, locale string) bool {
+	_, ok := ParseNumber(t, locale)
+	return ok
 }
 
-// removeThousands removes common thousand separators
+// removeThousands removes common thousand separators from amounts and
+// percentages, whose decimal separator is a point
 func removeThousands(s string) string {
 	r := strings.NewReplacer(",", "", "_", "", " ", "")
 	return r.Replace(s)
 }
 
-// parseDateAny tries to parse a string as a date using common formats
-func parseDateAny(t string) bool {
-	layouts := []string{
-		time.RFC3339,
-		"2006-01-02",
-		"2006-01-02 15:04:05",
-		"02/01/2006", "01/02/2006",
-		"02-01-2006", "01-02-2006",
-		"02 Jan 2006", "Jan 02, 2006",
-		"2006/01/02", "2006.01.02",
+// dateLayouts pairs the Go layouts tried by parseDateAny with their strftime
+// form, and with the form reading day and month the other way round
+var dateLayouts = []struct {
+	layout  string
+	format  string
+	swapped string
+}{
+	{time.RFC3339, "%Y-%m-%dT%H:%M:%S%z", ""},
+	{"2006-01-02", "%Y-%m-%d", ""},
+	{"2006-01-02 15:04:05", "%Y-%m-%d %H:%M:%S", ""},
+	{"02/01/2006", "%d/%m/%Y", "%m/%d/%Y"}, {"01/02/2006", "%m/%d/%Y", "%d/%m/%Y"},
+	{"02-01-2006", "%d-%m-%Y", "%m-%d-%Y"}, {"01-02-2006", "%m-%d-%Y", "%d-%m-%Y"},
+	{"02 Jan 2006", "%d %b %Y", ""}, {"Jan 02, 2006", "%b %d, %Y", ""},
+	{"2 Jan 2006", "%-d %b %Y", ""}, {"2. Jan 2006", "%-d. %b %Y", ""},
+	{"2006/01/02", "%Y/%m/%d", ""}, {"2006.01.02", "%Y.%m.%d", ""},
+}
+
+// allDateLayouts is a layout mask with every layout set
+var allDateLayouts = uint32(1)<<len(dateLayouts) - 1
+
+// parseDateAny tries to parse a string as a date using common formats,
+// with month names of locale
+func parseDateAny(t, locale string) bool {
+	return dateLayoutMask(t, locale) != 0
+}
+
+// dateLayoutMask returns a bit mask of the dateLayouts that parse t
+func dateLayoutMask(t, locale string) uint32 {
+	t = NormalizeMonths(t, locale)
+	var mask uint32
+	for i, l := range dateLayouts {
+		if _, err := time.Parse(l.layout, t); err == nil {
+			mask |= 1 << i
+		}
+	}
+	return mask
+}
+
+// dateFormat returns the strftime format of the first layout in mask
+func dateFormat(mask uint32) string {
+	for i, l := range dateLayouts {
+		if mask&(1<<i) != 0 {
+			return l.format
+		}
 	}
-	for _, layout := range layouts {
-		if _, err := time.Parse(layout, t); err == nil {
-			return true
+	return ""
+}
+
+// dateAmbiguous reports whether mask holds a layout and its day and month
+// swapped form, as for 03/04/2024, so the format cannot be told
+func dateAmbiguous(mask uint32) bool {
+	formats := make(map[string]bool)
+	for i, l := range dateLayouts {
+		if mask&(1<<i) != 0 {
+			formats[l.format] = true
+		}
+	}
+	for format := range formats {
+		for _, l := range dateLayouts {
+			if l.format == format && l.swapped != "" && formats[l.swapped] {
+				return true
+			}
 		}
 	}
 	return false
 }
 
-// getCellsTypes infers the data type for each column
-func getCellsTypes(lines []string, delimiter CandidateResult) []CellInference {
-	candidateCellTypes := make([]CellInference, delimiter.Status.ModeColumns)
-	columns := make([][]string, delimiter.Status.ModeColumns)
-	freq := make(map[InferredKind]int)
-	max := 0
+// piiKinds lists the PII kinds in the order cells are checked, the first
+// match wins
+var piiKinds = []string{
+	types.PIIEmail, types.PIIIBAN, types.PIICreditCard,
+	types.PIINationalID, types.PIIIPAddress, types.PIIPhone,
+}
 
-	for _, line := range lines {
-		if util.IsComment(line) {
-			continue
+// piiLabels describe the PII kinds in issue messages
+var piiLabels = map[string]string{
+	types.PIIEmail:      "email addresses",
+	types.PIIIBAN:       "IBANs",
+	types.PIICreditCard: "credit card numbers",
+	types.PIINationalID: "national ID numbers",
+	types.PIIIPAddress:  "IP addresses",
+	types.PIIPhone:      "phone numbers",
+}
+
+var (
+	emailPattern = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}$`)
+	ibanPattern  = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	cardPattern  = regexp.MustCompile(`^[0-9][0-9 -]{11,22}[0-9]$`)
+	phonePattern = regexp.MustCompile(`^\+?[0-9 ().-]+$`)
+
+	// US Social Security and UK National Insurance numbers
+	ssnPattern  = regexp.MustCompile(`^([0-9]{3})-([0-9]{2})-([0-9]{4})$`)
+	ninoPattern = regexp.MustCompile(`^[A-CEGHJ-PR-TW-Z]{2} ?[0-9]{2} ?[0-9]{2} ?[0-9]{2} ?[A-D]$`)
+)
+
+// inferPIIKind returns the kind of personal data a cell looks like, or ""
+func inferPIIKind(s string) string {
+	t := strings.TrimSpace(s)
+	switch {
+	case t == "" || isNullToken(t):
+		return ""
+	case emailPattern.MatchString(t):
+		return types.PIIEmail
+	case isIBAN(t):
+		return types.PIIIBAN
+	case isCardNumber(t):
+		return types.PIICreditCard
+	case isNationalID(t):
+		return types.PIINationalID
+	case isIPAddress(t):
+		return types.PIIIPAddress
+	case isPhoneNumber(t):
+		return types.PIIPhone
+	default:
+		return ""
+	}
+}
+
+// isIBAN checks the layout and the mod-97 check digits of an IBAN
+func isIBAN(t string) bool {
+	compact := strings.ReplaceAll(t, " ", "")
+	if !ibanPattern.MatchString(compact) {
+		return false
+	}
+
+	// Move the country code and check digits to the end, letters count as 10..35
+	var digits strings.Builder
+	for _, c := range compact[4:] + compact[:4] {
+		if c >= 'A' && c <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(c-'A') + 10))
+		} else {
+			digits.WriteRune(c)
 		}
-		fields, invalid := SplitLineFields(line, delimiter.Delimiter)
-		if invalid || len(fields) != delimiter.Status.ModeColumns {
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// isCardNumber checks for 13 to 19 digits passing the Luhn check
+func isCardNumber(t string) bool {
+	if !cardPattern.MatchString(t) {
+		return false
+	}
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(t)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// isNationalID checks for a valid US SSN or a UK National Insurance number
+func isNationalID(t string) bool {
+	if m := ssnPattern.FindStringSubmatch(t); m != nil {
+		return m[1] != "000" && m[1] != "666" && m[1][0] != '9' && m[2] != "00" && m[3] != "0000"
+	}
+	return ninoPattern.MatchString(t)
+}
+
+// isIPAddress checks for an IPv4 or IPv6 address
+func isIPAddress(t string) bool {
+	return strings.ContainsAny(t, ".:") && net.ParseIP(t) != nil
+}
+
+// isPhoneNumber checks for 7 to 15 digits with an international prefix or
+// separators, which plain numbers and dates do not have
+func isPhoneNumber(t string) bool {
+	if !phonePattern.MatchString(t) {
+		return false
+	}
+	digits := 0
+	for _, c := range t {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	if digits < 7 || digits > 15 {
+		return false
+	}
+	if _, err := strconv.ParseFloat(t, 64); err == nil {
+		return false
+	}
+	return !parseDateAny(t, "")
+}
+
+// piiIssues flags the columns whose sampled values mostly look like one kind
+// of personal data, with the share of matching values as confidence
+func piiIssues(columns []types.Column, values [][]string, minShare float64) []types.Issue {
+	var issues []types.Issue
+	for i, col := range columns {
+		if i >= len(values) {
+			break
+		}
+
+		counts := make(map[string]int)
+		nonNull := 0
+		for _, value := range values[i] {
+			if IsNullValue(value) {
+				continue
+			}
+			nonNull++
+			if kind := inferPIIKind(value); kind != "" {
+				counts[kind]++
+			}
+		}
+
+		best := ""
+		for _, kind := range piiKinds {
+			if counts[kind] > counts[best] {
+				best = kind
+			}
+		}
+		if best == "" {
 			continue
 		}
-		for i, field := range fields {
-			columns[i] = append(columns[i], field)
+		share := float64(counts[best]) / float64(nonNull)
+		if share < minShare {
+			continue
 		}
+
+		issues = append(issues, types.Issue{
+			Code:       "PII_" + strings.ToUpper(best),
+			Message:    fmt.Sprintf("Column %s looks like %s (%.0f%% of sampled values)", col.Name, piiLabels[best], share*100),
+			Column:     col.Name,
+			Confidence: math.Round(share*100) / 100,
+		})
 	}
+	return issues
+}
+
+// getCellsTypes infers the data type for each column, reading numbers and
+// dates in locale, or in the locale detected per column when it is empty
+func getCellsTypes(lines []string, delimiter CandidateResult, locale string) []CellInference {
+	columns := splitColumns(lines, delimiter)
+	candidateCellTypes := make([]CellInference, len(columns))
+	freq := make(map[InferredKind]int)
+	max := 0
 
+	locales := columnLocales(columns, locale)
 	for i, column := range columns {
+		columnLocale := locales[i]
+
+		layouts := allDateLayouts
 		for _, cell := range column {
-			cellType := inferCellType(cell)
+			cellType := inferCellType(cell, columnLocale)
 			if cellType.Kind == KindEmpty {
 				continue
 			}
+			if cellType.Kind == KindDate {
+				// Keep only the layouts every date cell agrees on
+				layouts &= dateLayoutMask(strings.TrimSpace(cell), columnLocale)
+			}
 			freq[cellType.Kind]++
 			if freq[cellType.Kind] > max {
 				max = freq[cellType.Kind]
 				candidateCellTypes[i] = cellType
 			}
 		}
+		switch candidateCellTypes[i].Kind {
+		case KindDate:
+			// Guessing between day and month first would silently move dates
+			if dateAmbiguous(layouts) {
+				candidateCellTypes[i].AmbiguousDates = true
+			} else {
+				candidateCellTypes[i].Format = dateFormat(layouts)
+			}
+			candidateCellTypes[i].Locale = columnLocale
+		case KindInt, KindFloat:
+			candidateCellTypes[i].Locale = columnLocale
+		}
 		freq = map[InferredKind]int{}
 		max = 0
 	}
@@ -161,9 +422,43 @@ func getCellsTypes(lines []string, delimiter CandidateResult) []CellInference {
 	return candidateCellTypes
 }
 
-// hasHeaders detects if the first row is a header row
-func hasHeaders(lines []string, delimiter CandidateResult, cellTypes []CellInference) (bool, []string) {
-	candidateHeader := make([]string, 0, delimiter.Status.ModeColumns)
+// columnLocales returns the locale of each column: locale when set, else
+// the detected one. Columns without evidence, like 1.000 or plain integers,
+// take the locale most other columns agree on
+func columnLocales(columns [][]string, locale string) []string {
+	locales := make([]string, len(columns))
+	if locale != "" {
+		for i := range locales {
+			locales[i] = locale
+		}
+		return locales
+	}
+
+	votes := make(map[string]int)
+	for i, column := range columns {
+		locales[i] = detectLocale(column)
+		if locales[i] != "" {
+			votes[locales[i]]++
+		}
+	}
+
+	fallback := ""
+	for tag, n := range votes {
+		if n > votes[fallback] || (n == votes[fallback] && tag < fallback) {
+			fallback = tag
+		}
+	}
+	for i := range locales {
+		if locales[i] == "" {
+			locales[i] = fallback
+		}
+	}
+	return locales
+}
+
+// splitColumns splits the lines matching the delimiter's mode field count into columns
+func splitColumns(lines []string, delimiter CandidateResult) [][]string {
+	columns := make([][]string, delimiter.Status.ModeColumns)
 
 	for _, line := range lines {
 		if util.IsComment(line) {
@@ -173,13 +468,20 @@ func hasHeaders(lines []string, delimiter CandidateResult, cellTypes []CellInfer
 		if invalid || len(fields) != delimiter.Status.ModeColumns {
 			continue
 		}
-
-		candidateHeader = fields
-		break
+		for i, field := range fields {
+			columns[i] = append(columns[i], field)
+		}
 	}
 
+	return columns
+}
+
+// hasHeaders detects if the first row is a header row
+func hasHeaders(lines []string, delimiter CandidateResult, cellTypes []CellInference) (bool, []string) {
+	candidateHeader := headerCandidate(lines, delimiter)
+
 	for i, cell := range candidateHeader {
-		cellType := inferCellType(cell)
+		cellType := inferCellType(cell, cellTypes[i].Locale)
 		if cellType.Kind != cellTypes[i].Kind {
 			return true, candidateHeader
 		}
@@ -187,3 +489,18 @@ func hasHeaders(lines []string, delimiter CandidateResult, cellTypes []CellInfer
 
 	return false, nil
 }
+
+// headerCandidate returns the fields of the first line matching the delimiter's mode field count
+func headerCandidate(lines []string, delimiter CandidateResult) []string {
+	for _, line := range lines {
+		if util.IsComment(line) {
+			continue
+		}
+		fields, invalid := SplitLineFields(line, delimiter.Delimiter)
+		if invalid || len(fields) != delimiter.Status.ModeColumns {
+			continue
+		}
+		return fields
+	}
+	return nil
+}
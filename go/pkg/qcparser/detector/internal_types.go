@@ -1,5 +1,7 @@
 package detector
 
+import "querycraft/pkg/qcparser/types"
+
 // Internal analysis types used during detection
 
 // LineAnalysis contains analysis results for a single line
@@ -39,11 +41,16 @@ type ScoreWeights struct {
 
 // DefaultScoreWeights returns default scoring weights
 func DefaultScoreWeights() ScoreWeights {
+	return scoreWeights(types.DefaultHeuristics())
+}
+
+// scoreWeights returns the scoring weights of the given heuristics
+func scoreWeights(h types.Heuristics) ScoreWeights {
 	return ScoreWeights{
-		CoverageWeight: 0.65,
-		SpreadWeight:   0.20,
-		InvalidWeight:  0.15,
-		QuoteWeight:    0.10,
+		CoverageWeight: h.CoverageWeight,
+		SpreadWeight:   h.SpreadWeight,
+		InvalidWeight:  h.InvalidWeight,
+		QuoteWeight:    h.QuoteWeight,
 	}
 }
 
@@ -64,3 +71,19 @@ type Decision struct {
 	EligibleCount    int
 	CandidateCount   int
 }
+
+// TailSample contains the last lines of a file that did not fit in the head sample
+type TailSample struct {
+	Lines      []string
+	countLines func() (int, error) // Counts the lines of the whole file
+}
+
+// FirstLine returns the 1-based line number of Lines[0]. It scans the whole
+// file, so it is only called once a footer was found.
+func (t *TailSample) FirstLine() (int, error) {
+	total, err := t.countLines()
+	if err != nil {
+		return 0, err
+	}
+	return total - len(t.Lines) + 1, nil
+}
@@ -0,0 +1,276 @@
+package detector
+
+import (
+	"querycraft/pkg/qcparser/types"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// localeInfo holds the separators and month names of a locale
+type localeInfo struct {
+	decimal   string
+	thousands []string
+	months    [12]string // Space-separated lowercase month names and abbreviations
+}
+
+// locales lists the known locales, English first so it wins month name ties
+var locales = []struct {
+	tag  string
+	info localeInfo
+}{
+	{types.LocaleEN, localeInfo{".", []string{",", "_", " ", "\u00a0"}, [12]string{
+		"january jan", "february feb", "march mar", "april apr", "may", "june jun",
+		"july jul", "august aug", "september sep sept", "october oct", "november nov", "december dec"}}},
+	{types.LocaleDE, localeInfo{",", []string{".", " ", "\u00a0"}, [12]string{
+		"januar jan jänner", "februar feb", "märz mär mrz maerz", "april apr", "mai", "juni jun",
+		"juli jul", "august aug", "september sep sept", "oktober okt", "november nov", "dezember dez"}}},
+	{types.LocaleFR, localeInfo{",", []string{" ", "\u00a0", "\u202f", "."}, [12]string{
+		"janvier janv", "février fevrier févr fevr", "mars", "avril avr", "mai", "juin",
+		"juillet juil", "août aout", "septembre sept", "octobre oct", "novembre nov", "décembre decembre déc"}}},
+	{types.LocaleES, localeInfo{",", []string{".", " ", "\u00a0"}, [12]string{
+		"enero ene", "febrero feb", "marzo mar", "abril abr", "mayo may", "junio jun",
+		"julio jul", "agosto ago", "septiembre setiembre sep sept", "octubre oct", "noviembre nov", "diciembre dic"}}},
+	{types.LocaleIT, localeInfo{",", []string{".", " ", "\u00a0"}, [12]string{
+		"gennaio gen", "febbraio feb", "marzo mar", "aprile apr", "maggio mag", "giugno giu",
+		"luglio lug", "agosto ago", "settembre set", "ottobre ott", "novembre nov", "dicembre dic"}}},
+	{types.LocalePT, localeInfo{",", []string{".", " ", "\u00a0"}, [12]string{
+		"janeiro jan", "fevereiro fev", "março marco mar", "abril abr", "maio mai", "junho jun",
+		"julho jul", "agosto ago", "setembro set", "outubro out", "novembro nov", "dezembro dez"}}},
+	{types.LocaleNL, localeInfo{",", []string{".", " ", "\u00a0"}, [12]string{
+		"januari jan", "februari feb", "maart mrt", "april apr", "mei", "juni jun",
+		"juli jul", "augustus aug", "september sep", "oktober okt", "november nov", "december dec"}}},
+}
+
+// monthNames maps each locale's lowercase month names to the month index
+var monthNames = func() map[string]map[string]int {
+	names := make(map[string]map[string]int, len(locales))
+	for _, l := range locales {
+		names[l.tag] = make(map[string]int)
+		for i, month := range l.info.months {
+			for _, name := range strings.Fields(month) {
+				names[l.tag][name] = i
+			}
+		}
+	}
+	return names
+}()
+
+// englishMonths are the month abbreviations understood by the date layouts
+var englishMonths = [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+// wordPattern matches a word with an optional abbreviation dot
+var wordPattern = regexp.MustCompile(`\p{L}+\.?`)
+
+// lookupLocale returns the separators and month names of a locale, English
+// for empty or unknown tags
+func lookupLocale(tag string) localeInfo {
+	for _, l := range locales {
+		if l.tag == tag {
+			return l.info
+		}
+	}
+	return locales[0].info
+}
+
+// NumberSeparators returns the decimal separator and the thousand
+// separators of locale; an empty locale is English
+func NumberSeparators(locale string) (string, []string) {
+	info := lookupLocale(locale)
+	return info.decimal, info.thousands
+}
+
+// normalizeNumber rewrites a number written in locale into Go syntax,
+// checking that thousand separators split the integer part in groups of three
+func normalizeNumber(t, locale string) (string, bool) {
+	info := lookupLocale(locale)
+
+	sign := ""
+	if strings.HasPrefix(t, "-") || strings.HasPrefix(t, "+") {
+		sign, t = t[:1], t[1:]
+	}
+
+	whole, frac, hasFrac := strings.Cut(t, info.decimal)
+	if hasFrac && strings.Contains(frac, info.decimal) {
+		return "", false
+	}
+
+	// Split the integer part on every thousand separator
+	groups := []string{whole}
+	for _, sep := range info.thousands {
+		var split []string
+		for _, g := range groups {
+			split = append(split, strings.Split(g, sep)...)
+		}
+		groups = split
+	}
+	if len(groups) > 1 {
+		for i, g := range groups {
+			if !isDigits(g) || (i == 0 && len(g) > 3) || (i > 0 && len(g) != 3) {
+				return "", false
+			}
+		}
+	}
+
+	normalized := sign + strings.Join(groups, "")
+	if hasFrac {
+		// A separator in the fraction is not a number of this locale
+		for _, sep := range info.thousands {
+			if strings.Contains(frac, sep) {
+				return "", false
+			}
+		}
+		normalized += "." + frac
+	}
+	return normalized, true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseNumber parses a numeric cell written in locale, accepting its
+// thousand separators; an empty locale is English
+func ParseNumber(s, locale string) (float64, bool) {
+	normalized, ok := normalizeNumber(strings.TrimSpace(s), locale)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(normalized, 64)
+	return v, err == nil
+}
+
+// ParseInteger parses an integer cell written in locale, accepting its
+// thousand separators; an empty locale is English
+func ParseInteger(s, locale string) (int64, bool) {
+	normalized, ok := normalizeNumber(strings.TrimSpace(s), locale)
+	if !ok || strings.ContainsAny(normalized, ".eE") {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(normalized, 10, 64)
+	return v, err == nil
+}
+
+// NormalizeMonths replaces the month names of locale in a date cell with
+// the English abbreviations of the date layouts, dropping the "de" of
+// Spanish and Portuguese dates
+func NormalizeMonths(s, locale string) string {
+	names, ok := monthNames[locale]
+	if !ok {
+		names = monthNames[types.LocaleEN]
+	}
+	dropDe := locale == types.LocaleES || locale == types.LocalePT
+
+	normalized := wordPattern.ReplaceAllStringFunc(s, func(word string) string {
+		key := strings.ToLower(strings.TrimSuffix(word, "."))
+		if i, ok := names[key]; ok {
+			return englishMonths[i]
+		}
+		if dropDe && (key == "de" || key == "del") {
+			return ""
+		}
+		return word
+	})
+	if dropDe {
+		normalized = strings.Join(strings.Fields(normalized), " ")
+	}
+	return normalized
+}
+
+// detectLocale guesses the locale of a column from its cells: month names
+// when the cells are dates in that language, else the decimal separator
+// of the numbers that only parse in one style
+func detectLocale(cells []string) string {
+	if locale := monthLocale(cells); locale != "" {
+		return locale
+	}
+	return numberLocale(cells)
+}
+
+// monthLocale returns the locale whose month names turn most cells into
+// dates, or "" when the cells hold no localized month names
+func monthLocale(cells []string) string {
+	votes := make(map[string]int)
+	nonNull := 0
+	for _, cell := range cells {
+		t := strings.TrimSpace(cell)
+		if t == "" || isNullToken(t) {
+			continue
+		}
+		nonNull++
+		words := wordPattern.FindAllString(t, -1)
+		for _, l := range locales {
+			for _, word := range words {
+				if _, ok := monthNames[l.tag][strings.ToLower(strings.TrimSuffix(word, "."))]; ok {
+					votes[l.tag]++
+					break
+				}
+			}
+		}
+	}
+
+	best := ""
+	for _, l := range locales {
+		if votes[l.tag] > votes[best] {
+			best = l.tag
+		}
+	}
+	if best == "" {
+		return ""
+	}
+
+	// Only date columns take their locale from month names
+	dates := 0
+	for _, cell := range cells {
+		if parseDateAny(strings.TrimSpace(cell), best) {
+			dates++
+		}
+	}
+	if dates*2 < nonNull {
+		return ""
+	}
+	return best
+}
+
+// numberLocale returns the locale of the decimal separator used by the
+// cells: en for a point, de for a comma, fr for a comma with thousands
+// grouped by spaces. Cells like 1,234 that parse in both styles give no
+// evidence; "" is returned without any
+func numberLocale(cells []string) string {
+	point, comma := 0, 0
+	spaces := false
+	for _, cell := range cells {
+		t := strings.TrimSpace(cell)
+		if t == "" || !strings.ContainsAny(t, ".,") {
+			continue
+		}
+		_, enOK := ParseNumber(t, types.LocaleEN)
+		_, deOK := ParseNumber(t, types.LocaleDE)
+		switch {
+		case enOK && !deOK:
+			point++
+		case deOK && !enOK:
+			comma++
+			spaces = spaces || strings.ContainsAny(t, " \u00a0\u202f")
+		}
+	}
+
+	switch {
+	case comma > point && spaces:
+		return types.LocaleFR
+	case comma > point:
+		return types.LocaleDE
+	case point > 0:
+		return types.LocaleEN
+	default:
+		return ""
+	}
+}
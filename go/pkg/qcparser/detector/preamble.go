@@ -0,0 +1,109 @@
+package detector
+
+import (
+	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
+	"strings"
+)
+
+// tableWindow is the number of lines checked after a candidate table start
+const tableWindow = 10
+
+// minTableCoverage is the share of window lines that must match the column count
+const minTableCoverage = 0.80
+
+// findPreamble returns the number of lines preceding the tabular block.
+// Each delimiter proposes a table start from its own mode column count,
+// and the candidate scoring best on the remaining lines decides.
+func findPreamble(lines []string, delimiters []rune, h types.Heuristics) int {
+	var best CandidateResult
+	bestStart := 0
+	found := false
+
+	for _, d := range delimiters {
+		candidate := scoreDelimiter(lines, d, h)
+		if candidate.Status.ModeColumns < 2 {
+			continue
+		}
+
+		start := findTableStart(lines, candidate)
+		candidate = scoreDelimiter(lines[start:], d, h)
+		if !candidate.Pass {
+			continue
+		}
+
+		if !found || compare(candidate, best) {
+			best = candidate
+			bestStart = start
+			found = true
+		}
+	}
+
+	return bestStart
+}
+
+// findTableStart returns the index of the first line of the tabular block.
+// Lines before it (report titles, dates, filter descriptions) are preamble.
+func findTableStart(lines []string, delimiter CandidateResult) int {
+	first := -1
+
+	for i, line := range lines {
+		if util.IsComment(line) || !matchesFieldCount(line, delimiter) {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+
+		if tableCoverage(lines[i:], delimiter) >= minTableCoverage {
+			return i
+		}
+	}
+
+	// No stable block found, fall back to the first well-formed line
+	if first < 0 {
+		return 0
+	}
+	return first
+}
+
+// tableCoverage returns the share of well-formed lines in the window, ignoring comments and blanks
+func tableCoverage(lines []string, delimiter CandidateResult) float64 {
+	checked := 0
+	matched := 0
+	for _, line := range lines {
+		if checked >= tableWindow {
+			break
+		}
+		if util.IsComment(line) {
+			continue
+		}
+		checked++
+		if matchesFieldCount(line, delimiter) {
+			matched++
+		}
+	}
+	if checked == 0 {
+		return 0
+	}
+	return float64(matched) / float64(checked)
+}
+
+// matchesFieldCount checks if a line splits into the winner's mode column count
+func matchesFieldCount(line string, delimiter CandidateResult) bool {
+	fields, invalid := SplitLineFields(line, delimiter.Delimiter)
+	return !invalid && len(fields) == delimiter.Status.ModeColumns
+}
+
+// preambleLines returns the non-blank skipped lines as metadata
+func preambleLines(lines []string) []string {
+	metadata := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		metadata = append(metadata, trimmed)
+	}
+	return metadata
+}
@@ -0,0 +1,42 @@
+package detector
+
+import (
+	"encoding/json"
+	"querycraft/pkg/qcparser/types"
+	"strings"
+)
+
+// FitFields applies the ragged row policy to a record whose field count differs
+// from width. It returns the fields to keep, any surplus fields to collect, and
+// false when the record must be rejected. counts is updated with the action taken.
+func FitFields(fields []string, width int, policy string, counts *types.RaggedCounts) ([]string, []string, bool) {
+	if len(fields) == width {
+		return fields, nil, true
+	}
+
+	switch {
+	case len(fields) < width && policy == types.RaggedPad:
+		// Missing trailing fields are left out and become nulls
+		counts.Padded++
+		return fields, nil, true
+	case len(fields) > width && policy == types.RaggedTruncate:
+		counts.Truncated++
+		return fields[:width], nil, true
+	case len(fields) > width && policy == types.RaggedExtra:
+		counts.Collected++
+		return fields[:width], fields[width:], true
+	}
+
+	counts.Rejected++
+	return nil, nil, false
+}
+
+// EncodeExtra serializes surplus fields as a JSON array for the ExtraColumn
+func EncodeExtra(extra []string) string {
+	trimmed := make([]string, len(extra))
+	for i, field := range extra {
+		trimmed[i] = strings.TrimSpace(field)
+	}
+	encoded, _ := json.Marshal(trimmed)
+	return string(encoded)
+}
@@ -0,0 +1,294 @@
+package detector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrSchemaMismatch is returned when the input does not fit a pinned schema
+var ErrSchemaMismatch = errors.New("schema mismatch")
+
+// LoadSchema reads and validates a schema document
+func LoadSchema(path string) (*types.SchemaDoc, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc types.SchemaDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	if err := ValidateSchema(&doc); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+
+	return &doc, nil
+}
+
+// ValidateSchema checks a schema document and fills in dialect defaults
+func ValidateSchema(doc *types.SchemaDoc) error {
+	if len(doc.Columns) == 0 {
+		return errors.New("no columns declared")
+	}
+
+	seen := make(map[string]string) // Lowercase name to declared name
+	for i := range doc.Columns {
+		col := &doc.Columns[i]
+		col.Type = strings.ToUpper(strings.TrimSpace(col.Type))
+		if col.Name == "" {
+			return fmt.Errorf("column %d has no name", i+1)
+		}
+		if _, dup := seen[strings.ToLower(col.Name)]; dup {
+			return fmt.Errorf("duplicate column %q", col.Name)
+		}
+		seen[strings.ToLower(col.Name)] = col.Name
+		if !types.IsColumnType(col.Type) {
+			return fmt.Errorf("column %q has unknown type %q", col.Name, col.Type)
+		}
+		if !types.IsLocale(col.Locale) {
+			return fmt.Errorf("column %q has unknown locale %q", col.Name, col.Locale)
+		}
+		if col.Pattern != "" {
+			if _, err := regexp.Compile(col.Pattern); err != nil {
+				return fmt.Errorf("column %q has invalid pattern: %w", col.Name, err)
+			}
+		}
+		if col.Min != nil && col.Max != nil && *col.Min > *col.Max {
+			return fmt.Errorf("column %q has min greater than max", col.Name)
+		}
+	}
+
+	// Key columns are spelled like their declaration, rows are keyed by it
+	for i, key := range doc.Key {
+		name, ok := seen[strings.ToLower(key)]
+		if !ok {
+			return fmt.Errorf("key column %q is not declared", key)
+		}
+		doc.Key[i] = name
+	}
+	if doc.MinRows != nil && doc.MaxRows != nil && *doc.MinRows > *doc.MaxRows {
+		return errors.New("min_rows is greater than max_rows")
+	}
+
+	dialect := &doc.Dialect
+	if dialect.Format == "" {
+		dialect.Format = "csv"
+	}
+	if dialect.Delimiter == "" {
+		dialect.Delimiter = ","
+	}
+	if doc.MatchBy == "" {
+		doc.MatchBy = types.MatchByPosition
+	}
+
+	switch dialect.Format {
+	case "csv":
+	case "jsonl", "json":
+		// The JSON readers are not implemented, conversion would never end
+		return fmt.Errorf("format %q cannot be read with a pinned schema, only csv", dialect.Format)
+	default:
+		return fmt.Errorf("unknown format %q", dialect.Format)
+	}
+	if utf8.RuneCountInString(dialect.Delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character, got %q", dialect.Delimiter)
+	}
+	if dialect.SkipRows < 0 || dialect.FooterRows < 0 {
+		return errors.New("skip_rows and footer_rows must not be negative")
+	}
+
+	switch doc.MatchBy {
+	case types.MatchByPosition:
+	case types.MatchByName:
+		if !dialect.HasHeader {
+			return errors.New("match_by name requires has_header")
+		}
+	default:
+		return fmt.Errorf("unknown match_by %q", doc.MatchBy)
+	}
+
+	return nil
+}
+
+// bindSchema builds a detection result from a pinned schema of a CSV input,
+// reading only the header (or first row) of the sample to match input columns
+func bindSchema(doc *types.SchemaDoc, lines []string, bytesRead int64, opts *types.Options, start time.Time) (*types.DetectResponse, error) {
+	dialect := doc.Dialect
+	response := &types.DetectResponse{
+		Format:     dialect.Format,
+		Encoding:   "utf-8",
+		Comment:    dialect.Comment,
+		HasHeader:  dialect.HasHeader,
+		SkipRows:   dialect.SkipRows,
+		FooterRows: dialect.FooterRows,
+		TrimFields: true,
+		Confidence: 1.0,
+		Issues:     []types.Issue{},
+		Sampled: types.SampledMeta{
+			Lines: len(lines),
+			Bytes: bytesRead,
+		},
+	}
+
+	delimiter := []rune(dialect.Delimiter)[0]
+	table := lines[util.Min(dialect.SkipRows, len(lines)):]
+
+	// The first row gives the input's field count, and its names when it is a header
+	var first []string
+	for _, line := range table {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || (dialect.Comment != nil && strings.HasPrefix(trimmed, *dialect.Comment)) {
+			continue
+		}
+		first, _ = SplitLineFields(line, delimiter)
+		break
+	}
+	if first == nil {
+		return nil, fmt.Errorf("%w: input has no rows", ErrSchemaMismatch)
+	}
+
+	var columns []types.Column
+	var err error
+	if doc.MatchBy == types.MatchByName {
+		columns, err = bindByName(doc, first)
+	} else {
+		columns, err = bindByPosition(doc, first)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.RaggedRows == types.RaggedExtra {
+		columns = append(columns, types.Column{
+			Name: types.ExtraColumn,
+			Type: "TEXT[]",
+		})
+	}
+
+	winner := CandidateResult{
+		Delimiter: delimiter,
+		Status:    DelimStatus{ModeColumns: len(first)},
+	}
+
+	response.Delimiter = &types.DelimiterInfo{
+		Delimiter:     dialect.Delimiter,
+		ConfidencePct: 100,
+	}
+	// Columns without a declared locale take the one of the options
+	for i := range columns {
+		if columns[i].Locale == "" {
+			columns[i].Locale = opts.Locale
+		}
+	}
+
+	response.FieldCount = len(first)
+	response.Columns = columns
+	response.Preview = generatePreview(table, winner, columns, dialect.HasHeader, opts.MaxPreviewRows, opts.RaggedRows)
+	response.DurationMs = util.DurationMs(start)
+	response.Sampled.DurationMs = response.DurationMs
+
+	return response, nil
+}
+
+// bindByPosition matches schema columns to input fields in order
+func bindByPosition(doc *types.SchemaDoc, first []string) ([]types.Column, error) {
+	columns := make([]types.Column, 0, len(doc.Columns))
+	missing := make([]string, 0)
+
+	for i, col := range doc.Columns {
+		if i >= len(first) && isRequired(col) {
+			missing = append(missing, col.Name)
+		}
+		// Optional columns past the end of the input are read as nulls
+		columns = append(columns, schemaColumn(col))
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: required columns missing from input: %s", ErrSchemaMismatch, strings.Join(missing, ", "))
+	}
+
+	extra := make([]string, 0)
+	for i := len(doc.Columns); i < len(first); i++ {
+		name := "col" + strconv.Itoa(i+1)
+		if doc.Dialect.HasHeader && strings.TrimSpace(first[i]) != "" {
+			name = strings.TrimSpace(first[i])
+		}
+		extra = append(extra, name)
+		columns = append(columns, types.Column{Name: name, Type: "TEXT"})
+	}
+	if len(extra) > 0 && !doc.AllowExtra {
+		return nil, fmt.Errorf("%w: unexpected extra columns in input: %s", ErrSchemaMismatch, strings.Join(extra, ", "))
+	}
+
+	return columns, nil
+}
+
+// bindByName matches schema columns to header names, in input order
+func bindByName(doc *types.SchemaDoc, header []string) ([]types.Column, error) {
+	byName := make(map[string]int, len(doc.Columns))
+	for i, col := range doc.Columns {
+		byName[strings.ToLower(col.Name)] = i
+	}
+
+	columns := make([]types.Column, 0, len(header))
+	used := make(map[int]bool)
+	extra := make([]string, 0)
+
+	for i, field := range header {
+		name := strings.TrimSpace(field)
+		if idx, ok := byName[strings.ToLower(name)]; ok && !used[idx] {
+			used[idx] = true
+			columns = append(columns, schemaColumn(doc.Columns[idx]))
+			continue
+		}
+
+		if name == "" {
+			name = "col" + strconv.Itoa(i+1)
+		}
+		extra = append(extra, name)
+		columns = append(columns, types.Column{Name: name, Type: "TEXT"})
+	}
+	if len(extra) > 0 && !doc.AllowExtra {
+		return nil, fmt.Errorf("%w: unexpected extra columns in input: %s", ErrSchemaMismatch, strings.Join(extra, ", "))
+	}
+
+	missing := make([]string, 0)
+	for i, col := range doc.Columns {
+		if used[i] {
+			continue
+		}
+		if isRequired(col) {
+			missing = append(missing, col.Name)
+			continue
+		}
+		// Absent optional columns come after the input fields and are always null
+		columns = append(columns, schemaColumn(col))
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: required columns missing from input: %s", ErrSchemaMismatch, strings.Join(missing, ", "))
+	}
+
+	return columns, nil
+}
+
+func schemaColumn(col types.SchemaColumn) types.Column {
+	return types.Column{
+		Name:     col.Name,
+		Type:     col.Type,
+		Format:   col.Format,
+		Nullable: col.Nullable,
+		Locale:   col.Locale,
+	}
+}
+
+func isRequired(col types.SchemaColumn) bool {
+	return col.Required == nil || *col.Required
+}
@@ -0,0 +1,264 @@
+package detector
+
+import (
+	"encoding/json"
+	"math"
+	"net/url"
+	"querycraft/pkg/qcparser/types"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semanticKinds lists the semantic types in the order cells are checked,
+// the first match wins
+var semanticKinds = []string{
+	types.SemanticJSON, types.SemanticURL, types.SemanticEmail,
+	types.SemanticUUID, types.SemanticMACAddress, types.SemanticIPv4,
+	types.SemanticIPv6, types.SemanticPercentage, types.SemanticCurrencyAmount,
+	types.SemanticLatitude, types.SemanticLongitude, types.SemanticCountryCode,
+	types.SemanticCurrencyCode, types.SemanticFilePath, types.SemanticHostname,
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}$`)
+	macPattern      = regexp.MustCompile(`^([0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}$|^([0-9A-Fa-f]{4}\.){2}[0-9A-Fa-f]{4}$`)
+	percentPattern  = regexp.MustCompile(`^[+-]?[0-9][0-9,]*(\.[0-9]+)? ?%$`)
+	amountPattern   = regexp.MustCompile(`^[+-]?[0-9][0-9,]*(\.[0-9]+)?$`)
+	hostnamePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)+[A-Za-z]{2,63}\.?$`)
+	pathPattern     = regexp.MustCompile(`^(/|~/|\./|\.\./|[A-Za-z]:\\|\\\\)[^\x00]*$`)
+	coordPattern    = regexp.MustCompile(`^[+-]?[0-9]{1,3}\.[0-9]{4,}$`)
+)
+
+// currencySymbols are the currency signs accepted before or after an amount
+var currencySymbols = []string{"US$", "$", "€", "£", "¥", "₹", "₩", "₽", "₺", "₪", "₫", "₱", "₦", "฿", "R$", "CHF", "kr"}
+
+// countryCodes are the ISO 3166-1 alpha-2 country codes
+var countryCodes = codeSet(`AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL
+BM BN BO BQ BR BS BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM
+DO DZ EC EE EG EH ER ES ET FI FJ FK FM FO FR GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY
+HK HM HN HR HT HU ID IE IL IM IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC
+LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ NA NC NE
+NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW SA SB SC SD
+SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ UA
+UG UM US UY UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW`)
+
+// currencyCodes are the active ISO 4217 currency codes
+var currencyCodes = codeSet(`AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB
+BRL BSD BTN BWP BYN BZD CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP
+GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW
+KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD NGN
+NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS
+SRD SSP STN SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU UZS VES VND VUV WST XAF XCD XOF
+XPF YER ZAR ZMW ZWL`)
+
+func codeSet(codes string) map[string]bool {
+	set := make(map[string]bool)
+	for _, code := range strings.Fields(codes) {
+		set[code] = true
+	}
+	return set
+}
+
+// inferSemanticType returns the semantic type a cell looks like, or "";
+// latitude and longitude cells are both reported as latitude and told
+// apart per column
+func inferSemanticType(s string) string {
+	t := strings.TrimSpace(s)
+	switch {
+	case t == "" || isNullToken(t):
+		return ""
+	case isJSONText(t):
+		return types.SemanticJSON
+	case isURL(t):
+		return types.SemanticURL
+	case emailPattern.MatchString(t):
+		return types.SemanticEmail
+	case uuidPattern.MatchString(t):
+		return types.SemanticUUID
+	case macPattern.MatchString(t):
+		return types.SemanticMACAddress
+	case isIPAddress(t) && strings.Contains(t, "."):
+		return types.SemanticIPv4
+	case isIPAddress(t):
+		return types.SemanticIPv6
+	case percentPattern.MatchString(t):
+		return types.SemanticPercentage
+	case isCurrencyAmount(t):
+		return types.SemanticCurrencyAmount
+	case isCoordinate(t, 180):
+		return types.SemanticLatitude
+	case countryCodes[t]:
+		return types.SemanticCountryCode
+	case currencyCodes[t]:
+		return types.SemanticCurrencyCode
+	case pathPattern.MatchString(t) && len(t) > 1:
+		return types.SemanticFilePath
+	case hostnamePattern.MatchString(t) && !parseFloatRelaxed(t, "") && !parseDateAny(t, ""):
+		return types.SemanticHostname
+	default:
+		return ""
+	}
+}
+
+// isJSONText checks for a JSON object or array held in text
+func isJSONText(t string) bool {
+	if !(strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}")) &&
+		!(strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]")) {
+		return false
+	}
+	return json.Valid([]byte(t))
+}
+
+// isURL checks for an absolute URL with a host
+func isURL(t string) bool {
+	if strings.ContainsAny(t, " \t") {
+		return false
+	}
+	u, err := url.Parse(t)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ftp", "ftps", "ws", "wss":
+		return u.Host != ""
+	default:
+		return false
+	}
+}
+
+// isCurrencyAmount checks for a number with a currency symbol or code
+// before or after it
+func isCurrencyAmount(t string) bool {
+	_, ok := ParseAmount(t)
+	return ok && stripCurrency(t) != t
+}
+
+// isCoordinate checks for a decimal degree within ±limit, with enough
+// fractional digits to tell it apart from ordinary measurements
+func isCoordinate(t string, limit float64) bool {
+	if !coordPattern.MatchString(t) {
+		return false
+	}
+	v, err := strconv.ParseFloat(t, 64)
+	return err == nil && math.Abs(v) <= limit
+}
+
+// stripCurrency removes a leading or trailing currency symbol or ISO code
+// from t, keeping a leading sign
+func stripCurrency(t string) string {
+	sign := ""
+	if strings.HasPrefix(t, "-") || strings.HasPrefix(t, "+") {
+		sign, t = t[:1], t[1:]
+	}
+	for _, symbol := range currencySymbols {
+		if strings.HasPrefix(t, symbol) {
+			return sign + strings.TrimSpace(strings.TrimPrefix(t, symbol))
+		}
+		if strings.HasSuffix(t, symbol) {
+			return sign + strings.TrimSpace(strings.TrimSuffix(t, symbol))
+		}
+	}
+	if len(t) > 4 {
+		if code := t[:3]; currencyCodes[code] && t[3] == ' ' {
+			return sign + strings.TrimSpace(t[4:])
+		}
+		if code := t[len(t)-3:]; currencyCodes[code] && t[len(t)-4] == ' ' {
+			return sign + strings.TrimSpace(t[:len(t)-4])
+		}
+	}
+	return sign + t
+}
+
+// ParsePercentage parses a percentage like "12.5%" into its fraction 0.125
+func ParsePercentage(s string) (float64, bool) {
+	t := strings.TrimSpace(s)
+	if !percentPattern.MatchString(t) {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(removeThousands(strings.TrimSuffix(t, "%")), 64)
+	return v / 100, err == nil
+}
+
+// ParseAmount parses a number with an optional currency symbol or code and
+// thousand separators, like "$1,200" or "EUR 9.99"
+func ParseAmount(s string) (float64, bool) {
+	t := stripCurrency(strings.TrimSpace(s))
+	if !amountPattern.MatchString(t) {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(removeThousands(t), 64)
+	return v, err == nil
+}
+
+// coordinateHint tells latitude and longitude columns apart by their name
+func coordinateHint(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	for _, word := range words {
+		switch word {
+		case "lat", "latitude":
+			return types.SemanticLatitude
+		case "lon", "lng", "long", "longitude":
+			return types.SemanticLongitude
+		}
+	}
+	return ""
+}
+
+// inferSemanticTypes sets the semantic type of the columns whose sampled
+// values mostly share one, with the share of matching values as confidence.
+// Coordinates need a latitude or longitude column name, since plain
+// decimals look the same
+func inferSemanticTypes(columns []types.Column, values [][]string, minShare float64) {
+	for i := range columns {
+		if i >= len(values) {
+			break
+		}
+
+		counts := make(map[string]int)
+		nonNull := 0
+		maxAbs := 0.0
+		for _, value := range values[i] {
+			if IsNullValue(value) {
+				continue
+			}
+			nonNull++
+			kind := inferSemanticType(value)
+			if kind == types.SemanticLatitude {
+				v, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				maxAbs = math.Max(maxAbs, math.Abs(v))
+			}
+			if kind != "" {
+				counts[kind]++
+			}
+		}
+
+		best := ""
+		for _, kind := range semanticKinds {
+			if counts[kind] > counts[best] {
+				best = kind
+			}
+		}
+		if best == types.SemanticLatitude {
+			best = coordinateHint(columns[i].Name)
+			if best == types.SemanticLatitude && maxAbs > 90 {
+				best = ""
+			}
+			if best != "" {
+				counts[best] = counts[types.SemanticLatitude]
+			}
+		}
+		if best == "" {
+			continue
+		}
+		share := float64(counts[best]) / float64(nonNull)
+		if share < minShare {
+			continue
+		}
+
+		columns[i].SemanticType = best
+		columns[i].SemanticConfidence = math.Round(share*100) / 100
+	}
+}
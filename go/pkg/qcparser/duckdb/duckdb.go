@@ -0,0 +1,308 @@
+package duckdb
+
+import (
+	"fmt"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/types"
+	"regexp"
+	"strings"
+)
+
+// Targets select which file the generated read clause loads
+const (
+	TargetDJSON  = "djson"  // DJSON written by qcparser.Convert
+	TargetSource = "source" // The original input file
+)
+
+// djsonTimestampFormat is the format the writer uses for TIMESTAMP values
+const djsonTimestampFormat = "%Y-%m-%d"
+
+// Schema builds DuckDB statements that load path into table with exactly
+// the column types in detected, instead of letting DuckDB re-infer them.
+// raggedRows is the ragged row policy the source file is read with
+func Schema(detected *types.DetectResponse, table string, path string, target string, raggedRows string) (*types.DuckDBSchema, error) {
+	if len(detected.Columns) == 0 {
+		return nil, fmt.Errorf("no columns detected")
+	}
+
+	var readClause string
+	var err error
+	switch target {
+	case TargetDJSON:
+		readClause = readDJSON(detected, path)
+	case TargetSource:
+		readClause, err = readSource(detected, path, raggedRows)
+	default:
+		err = fmt.Errorf("unknown target: %s", target)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.DuckDBSchema{
+		Table:       table,
+		Target:      target,
+		CreateTable: CreateTable(detected.Columns, table),
+		ReadClause:  readClause,
+		Load:        fmt.Sprintf("INSERT INTO %s SELECT %s FROM %s;", Ident(table), selectList(detected.Columns), readClause),
+	}, nil
+}
+
+// CreateTable returns a CREATE TABLE statement for the columns
+func CreateTable(columns []types.Column, table string) string {
+	defs := make([]string, len(columns))
+	for i, col := range columns {
+		defs[i] = fmt.Sprintf("%s %s", Ident(col.Name), ColumnType(col.Type))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s);", Ident(table), strings.Join(defs, ", "))
+}
+
+// ColumnType maps a detected column type to its DuckDB type
+func ColumnType(colType string) string {
+	switch colType {
+	case "INT":
+		// The writer emits 64-bit integers
+		return "BIGINT"
+	case "DOUBLE":
+		return "DOUBLE"
+	case "BOOLEAN":
+		return "BOOLEAN"
+	case "DATE":
+		return "DATE"
+	case "TIMESTAMP":
+		return "TIMESTAMP"
+	case "TEXT[]":
+		return "VARCHAR[]"
+	default:
+		return "VARCHAR"
+	}
+}
+
+// readDJSON builds a read_json clause for DJSON written by the converter
+func readDJSON(detected *types.DetectResponse, path string) string {
+	params := []string{
+		"format = 'newline_delimited'",
+		"columns = " + columnsStruct(detected.Columns, nil),
+	}
+	if hasType(detected.Columns, "TIMESTAMP") {
+		params = append(params, "timestampformat = "+Literal(djsonTimestampFormat))
+	}
+	return fmt.Sprintf("read_json(%s, %s)", Literal(path), strings.Join(params, ", "))
+}
+
+// readSource builds a read clause for the original input file
+func readSource(detected *types.DetectResponse, path string, raggedRows string) (string, error) {
+	switch detected.Format {
+	case "json", "jsonl":
+		format := "newline_delimited"
+		if detected.Format == "json" {
+			format = "array"
+		}
+		return fmt.Sprintf("read_json(%s, format = %s, columns = %s)",
+			Literal(path), Literal(format), columnsStruct(detected.Columns, nil)), nil
+	case "csv":
+		return readCSV(detected, path, raggedRows), nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", detected.Format)
+	}
+}
+
+// readCSV builds a read_csv clause with the detected dialect. Every column is
+// read as VARCHAR and cast in a wrapping SELECT, so that values the writer
+// turns into nulls are nulls here too instead of failing the load
+func readCSV(detected *types.DetectResponse, path string, raggedRows string) string {
+	columns := sourceColumns(detected.Columns)
+	asText := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		asText[col.Name] = true
+	}
+
+	params := []string{
+		"delim = " + Literal(detected.Delimiter.Delimiter),
+		"quote = '\"'",
+		"escape = '\"'",
+		fmt.Sprintf("header = %t", detected.HasHeader),
+		fmt.Sprintf("skip = %d", detected.SkipRows),
+		"auto_detect = false",
+		"columns = " + columnsStruct(columns, asText),
+		"nullstr = " + listLiteral(nullStrings()),
+	}
+	if detected.Comment != nil {
+		params = append(params, "comment = "+Literal(*detected.Comment))
+	}
+	params = append(params, raggedParams(raggedRows)...)
+	clause := fmt.Sprintf("read_csv(%s, %s)", Literal(path), strings.Join(params, ", "))
+
+	exprs := make([]string, 0, len(detected.Columns))
+	for _, col := range detected.Columns {
+		if col.Name == types.ExtraColumn {
+			// read_csv cannot collect the surplus fields
+			exprs = append(exprs, fmt.Sprintf("CAST(NULL AS %s) AS %s", ColumnType(col.Type), Ident(col.Name)))
+			continue
+		}
+		exprs = append(exprs, castExpr(col)+" AS "+Ident(col.Name))
+	}
+
+	// DuckDB has no option to skip trailing lines, drop the footer records
+	// by their position instead; read_csv keeps the file's row order
+	footer := footerRecords(detected, raggedRows)
+	if footer == 0 {
+		return fmt.Sprintf("(SELECT %s FROM %s)", strings.Join(exprs, ", "), clause)
+	}
+	return fmt.Sprintf("(SELECT %s FROM (SELECT *, row_number() OVER () AS %s FROM %s) WHERE %s <= (SELECT count(*) FROM %s) - %d)",
+		strings.Join(exprs, ", "), Ident(rowNumberColumn), clause, Ident(rowNumberColumn), clause, footer)
+}
+
+// rowNumberColumn numbers the records read from the source to find the footer
+const rowNumberColumn = "__qc_row"
+
+// raggedParams returns the read_csv options that apply the ragged row policy
+func raggedParams(raggedRows string) []string {
+	switch raggedRows {
+	case types.RaggedPad:
+		return []string{"null_padding = true", "ignore_errors = true"}
+	case types.RaggedTruncate, types.RaggedExtra:
+		return []string{"strict_mode = false", "ignore_errors = true"}
+	default:
+		return []string{"ignore_errors = true"}
+	}
+}
+
+// footerRecords returns how many of the footer lines read_csv returns as
+// records under the ragged row policy
+func footerRecords(detected *types.DetectResponse, raggedRows string) int {
+	if len(detected.Footer) == 0 {
+		// A pinned footer has no sampled lines, take every line for a record
+		return detected.FooterRows
+	}
+
+	delim := []rune(detected.Delimiter.Delimiter)[0]
+	records := 0
+	for _, line := range detected.Footer {
+		fields, invalid := detector.SplitLineFields(line, delim)
+		if invalid {
+			continue
+		}
+		if _, _, ok := detector.FitFields(fields, detected.FieldCount, raggedRows, &types.RaggedCounts{}); ok {
+			records++
+		}
+	}
+	return records
+}
+
+// castExpr casts a column read as VARCHAR to its detected type, with null for
+// values that do not parse, like the writer
+func castExpr(col types.Column) string {
+	name := Ident(col.Name)
+	switch col.Type {
+	case "TIMESTAMP", "DATE":
+		if col.Format == "" {
+			return fmt.Sprintf("TRY_CAST(%s AS %s)", name, ColumnType(col.Type))
+		}
+		return fmt.Sprintf("CAST(try_strptime(%s, %s) AS %s)", name, Literal(col.Format), ColumnType(col.Type))
+	case "INT", "DOUBLE":
+		return fmt.Sprintf("TRY_CAST(%s AS %s)", normalizeNumber(name, col.Locale), ColumnType(col.Type))
+	case "BOOLEAN", "TEXT[]":
+		return fmt.Sprintf("TRY_CAST(%s AS %s)", name, ColumnType(col.Type))
+	default:
+		return name
+	}
+}
+
+// normalizeNumber rewrites a number written in locale into DuckDB syntax, like
+// detector.ParseNumber: thousand separators must split the integer part in
+// groups of three and are dropped, the decimal separator becomes a point
+func normalizeNumber(expr string, locale string) string {
+	decimal, thousands := detector.NumberSeparators(locale)
+	quoted := make([]string, len(thousands))
+	for i, sep := range thousands {
+		quoted[i] = regexp.QuoteMeta(sep)
+	}
+	sep := "[" + strings.Join(quoted, "") + "]"
+	grouped := `[+-]?[0-9]{1,3}(` + sep + `[0-9]{3})+(` + regexp.QuoteMeta(decimal) + `[0-9]+)?`
+
+	value := fmt.Sprintf("trim(%s)", expr)
+	normalized := fmt.Sprintf("CASE WHEN regexp_full_match(%s, %s) THEN regexp_replace(%s, %s, '', 'g') WHEN regexp_matches(%s, %s) THEN NULL ELSE %s END",
+		value, Literal(grouped), value, Literal(sep), value, Literal(sep), value)
+	if decimal != "." {
+		normalized = fmt.Sprintf("replace(%s, %s, '.')", normalized, Literal(decimal))
+	}
+	return normalized
+}
+
+// sourceColumns drops columns that only exist in the converted output
+func sourceColumns(columns []types.Column) []types.Column {
+	source := make([]types.Column, 0, len(columns))
+	for _, col := range columns {
+		if col.Name == types.ExtraColumn {
+			continue
+		}
+		source = append(source, col)
+	}
+	return source
+}
+
+// columnsStruct renders a {'name': 'TYPE', ...} struct literal
+func columnsStruct(columns []types.Column, asText map[string]bool) string {
+	entries := make([]string, len(columns))
+	for i, col := range columns {
+		colType := ColumnType(col.Type)
+		if asText[col.Name] {
+			colType = "VARCHAR"
+		}
+		entries[i] = fmt.Sprintf("%s: %s", Literal(col.Name), Literal(colType))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// selectList renders the quoted column names for an INSERT ... SELECT
+func selectList(columns []types.Column) string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = Ident(col.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// nullStrings expands the detector's null tokens into the spellings DuckDB must match
+func nullStrings() []string {
+	seen := map[string]bool{"": true}
+	values := []string{""}
+	for _, token := range detector.NullTokens() {
+		for _, v := range []string{token, strings.ToUpper(token), strings.ToUpper(token[:1]) + token[1:]} {
+			if !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+func hasType(columns []types.Column, colType string) bool {
+	for _, col := range columns {
+		if col.Type == colType {
+			return true
+		}
+	}
+	return false
+}
+
+// Ident quotes a DuckDB identifier
+func Ident(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Literal quotes a DuckDB string literal
+func Literal(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// listLiteral renders a ['a', 'b'] list literal
+func listLiteral(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = Literal(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
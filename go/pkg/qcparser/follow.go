@@ -0,0 +1,400 @@
+package qcparser
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/internal/mask"
+	"querycraft/pkg/qcparser/internal/reader"
+	"querycraft/pkg/qcparser/internal/transform"
+	"querycraft/pkg/qcparser/internal/writer"
+	"querycraft/pkg/qcparser/types"
+	"time"
+)
+
+const (
+	// followBatchBytes bounds the input converted between two appended events
+	followBatchBytes = 4 << 20
+
+	// fingerprintBytes is the length of the input head hashed to recognise it
+	fingerprintBytes = 1024
+)
+
+// FollowStatePath returns the path of the offset file kept next to a followed output
+func FollowStatePath(outputPath string) string {
+	return outputPath + ".follow.json"
+}
+
+// Follow converts a growing file like `tail -F`: records completed after the
+// last poll are appended to the DJSON output, a rotated or truncated input
+// is read again from its start, and the offset is persisted so a restart
+// resumes where the previous run stopped. It returns when ctx is cancelled.
+func Follow(ctx context.Context, filePath string, outputPath string, opts *types.Options, interval time.Duration, events types.FollowFunc) (*types.ConvertResult, error) {
+	start := time.Now()
+	if events == nil {
+		events = func(types.FollowEvent) {}
+	}
+
+	f := &follower{path: filePath, statePath: FollowStatePath(outputPath), opts: opts, events: events}
+	if err := f.open(outputPath); err != nil {
+		return nil, err
+	}
+	defer f.close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.drain(ctx); err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return &types.ConvertResult{
+				DJSONPath:    outputPath,
+				RowsWritten:  f.state.RowsWritten,
+				RowsFiltered: f.state.RowsFiltered,
+				BytesWritten: f.state.BytesWritten,
+				DurationMs:   time.Since(start).Milliseconds(),
+				Errors:       []string{},
+				RaggedRows:   f.ragged,
+			}, nil
+		case <-ticker.C:
+		}
+
+		if err := f.reopen(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// follower holds the open input and output of a followed conversion
+type follower struct {
+	path      string
+	statePath string
+	opts      *types.Options
+	events    types.FollowFunc
+
+	input  *os.File
+	info   os.FileInfo
+	output *os.File
+	state  types.FollowState
+	ragged types.RaggedCounts
+	buf    []byte
+
+	pipeline *transform.Pipeline
+	masker   *mask.Masker
+	written  *types.DetectResponse // Columns of the transformed and masked rows
+}
+
+// open restores the saved state, or detects the input and starts a new output
+func (f *follower) open(outputPath string) error {
+	input, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	f.input = input
+	if f.info, err = input.Stat(); err != nil {
+		return err
+	}
+
+	resumed, err := f.loadState()
+	if err != nil {
+		return err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !resumed {
+		detected, err := detector.Detect(f.path, f.opts)
+		if err != nil {
+			return fmt.Errorf("detection failed: %w", err)
+		}
+		// Only the CSV reader is implemented, the JSON ones never yield rows
+		if detected.Format != "csv" {
+			return fmt.Errorf("a %s input cannot be followed, only csv", detected.Format)
+		}
+		// A growing input has no footer yet, and the preview is not needed
+		detected.FooterRows = 0
+		detected.Preview = types.Preview{}
+		f.state = types.FollowState{Config: *detected}
+		flags |= os.O_TRUNC
+	}
+
+	// Transforms and masks are resolved once against the followed columns
+	if f.pipeline, f.written, err = compileTransforms(&f.state.Config, f.opts); err != nil {
+		return err
+	}
+	if f.masker, f.written, err = compileMasks(f.written, f.opts); err != nil {
+		return err
+	}
+	if err := writer.CheckPlan(f.written, f.opts); err != nil {
+		return err
+	}
+
+	if f.output, err = os.OpenFile(outputPath, flags, 0644); err != nil {
+		return err
+	}
+	// Drop output written after the last saved state
+	if resumed {
+		if err := f.output.Truncate(f.state.BytesWritten); err != nil {
+			return err
+		}
+	}
+
+	// The input may have been replaced while no one was following it
+	if resumed && !f.sameInput() {
+		f.restart(types.FollowRotated)
+	}
+	return f.saveState()
+}
+
+// loadState reads the saved state, reporting whether there was one
+func (f *follower) loadState() (bool, error) {
+	data, err := os.ReadFile(f.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, &f.state); err != nil {
+		return false, fmt.Errorf("invalid follow state %s: %w", f.statePath, err)
+	}
+	return true, nil
+}
+
+// saveState atomically replaces the state file
+func (f *follower) saveState() error {
+	if f.state.HeadBytes < fingerprintBytes && f.state.Offset > f.state.HeadBytes {
+		fingerprint, n, err := f.fingerprint(fingerprintBytes)
+		if err != nil {
+			return err
+		}
+		f.state.Fingerprint, f.state.HeadBytes = fingerprint, n
+	}
+
+	data, err := json.Marshal(f.state)
+	if err != nil {
+		return err
+	}
+	tmp := f.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.statePath)
+}
+
+// fingerprint hashes up to limit converted bytes from the start of the input
+func (f *follower) fingerprint(limit int64) (string, int64, error) {
+	head := make([]byte, min(limit, f.state.Offset))
+	n, err := f.input.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+	sum := sha256.Sum256(head[:n])
+	return hex.EncodeToString(sum[:]), int64(n), nil
+}
+
+// sameInput checks that the open input still starts with the converted bytes
+func (f *follower) sameInput() bool {
+	if f.info.Size() < f.state.Offset {
+		return false
+	}
+	if f.state.HeadBytes == 0 {
+		return true
+	}
+	fingerprint, n, err := f.fingerprint(f.state.HeadBytes)
+	return err == nil && n == f.state.HeadBytes && fingerprint == f.state.Fingerprint
+}
+
+// restart reads the input again from its start
+func (f *follower) restart(reason string) {
+	f.state.Offset = 0
+	f.state.Line = 0
+	f.state.Fingerprint = ""
+	f.state.HeadBytes = 0
+	f.emit(reason, 0, []string{}, types.RaggedCounts{})
+}
+
+// reopen follows the path to a new file after rotation, and starts over
+// when the input shrank below the offset
+func (f *follower) reopen() error {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Rotated away and not created again yet, keep the old file
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !os.SameFile(info, f.info) {
+		// Convert what was appended to the old file before switching
+		if err := f.drain(context.Background()); err != nil {
+			return err
+		}
+		input, err := os.Open(f.path)
+		if err != nil {
+			return err
+		}
+		f.input.Close()
+		f.input = input
+		if f.info, err = input.Stat(); err != nil {
+			return err
+		}
+		f.restart(types.FollowRotated)
+		return f.saveState()
+	}
+
+	f.info = info
+	if info.Size() < f.state.Offset {
+		f.restart(types.FollowTruncated)
+		return f.saveState()
+	}
+	return nil
+}
+
+// drain converts every completed record past the offset, one batch at a time
+func (f *follower) drain(ctx context.Context) error {
+	for ctx.Err() == nil {
+		chunk, err := f.completed()
+		if err != nil || chunk == nil {
+			return err
+		}
+		if err := f.append(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completed returns the input past the offset up to the end of its last record,
+// or nil when no record was completed since the last batch
+func (f *follower) completed() ([]byte, error) {
+	if f.buf == nil {
+		f.buf = make([]byte, followBatchBytes)
+	}
+	n, err := f.input.ReadAt(f.buf, f.state.Offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	end := recordEnd(f.buf[:n])
+	if end < 0 {
+		if n == len(f.buf) {
+			return nil, fmt.Errorf("record at offset %d is longer than %d bytes", f.state.Offset, len(f.buf))
+		}
+		return nil, nil
+	}
+	chunk := f.buf[:end+1]
+
+	// Wait until the header and preamble are complete
+	if f.state.Offset == 0 {
+		headLines := f.state.Config.SkipRows
+		if f.state.Config.HasHeader {
+			headLines++
+		}
+		if bytes.Count(chunk, []byte{'\n'}) <= headLines {
+			return nil, nil
+		}
+	}
+	return chunk, nil
+}
+
+// recordEnd returns the index of the last newline of buf, or -1. The
+// reader splits records on every newline, quoted or not, so a line with an
+// unbalanced quote is one rejected record and the lines after it are not.
+func recordEnd(buf []byte) int {
+	return bytes.LastIndexByte(buf, '\n')
+}
+
+// append converts a batch of records, appends it to the output and saves
+// the new offset
+func (f *follower) append(chunk []byte) error {
+	// Only the start of the input holds a preamble and a header
+	config := f.state.Config
+	if f.state.Offset > 0 {
+		config.SkipRows = 0
+		config.HasHeader = false
+	}
+
+	rowChan, errChan, stats := reader.ReadFrom(bytes.NewReader(chunk), &config, f.opts)
+	wait := collectLineErrors(errChan, f.state.Line)
+
+	result, err := writer.WriteTo(maskRows(transformRows(rowChan, f.pipeline), f.masker), f.written, f.opts, f.output)
+	if err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	lineErrors := wait()
+	if err := f.output.Sync(); err != nil {
+		return err
+	}
+
+	f.state.Offset += int64(len(chunk))
+	f.state.Line += bytes.Count(chunk, []byte{'\n'})
+	f.state.RowsWritten += result.RowsWritten
+	f.state.RowsFiltered += result.RowsFiltered
+	f.state.BytesWritten += result.BytesWritten
+	addRagged(&f.ragged, stats.Ragged)
+	if err := f.saveState(); err != nil {
+		return err
+	}
+
+	f.emit(types.FollowAppended, result.RowsWritten, lineErrors, stats.Ragged)
+	return nil
+}
+
+// emit sends an event with the current totals
+func (f *follower) emit(kind string, appended int64, lineErrors []string, ragged types.RaggedCounts) {
+	f.events(types.FollowEvent{
+		Type:         kind,
+		Offset:       f.state.Offset,
+		RowsAppended: appended,
+		RowsWritten:  f.state.RowsWritten,
+		BytesWritten: f.state.BytesWritten,
+		Errors:       lineErrors,
+		RaggedRows:   ragged,
+	})
+}
+
+func (f *follower) close() {
+	if f.input != nil {
+		f.input.Close()
+	}
+	if f.output != nil {
+		f.output.Close()
+	}
+}
+
+// collectLineErrors is like collectErrors for a batch starting after
+// firstLine input lines, numbering line errors from the start of the input
+func collectLineErrors(errChan <-chan error, firstLine int) func() []string {
+	offset := make(chan error)
+	go func() {
+		defer close(offset)
+		for err := range errChan {
+			var lineErr *reader.LineError
+			if errors.As(err, &lineErr) {
+				lineErr.Line += firstLine
+			}
+			offset <- err
+		}
+	}()
+	return collectErrors(offset)
+}
+
+// addRagged adds the counts of a batch to a total
+func addRagged(total *types.RaggedCounts, batch types.RaggedCounts) {
+	total.Rejected += batch.Rejected
+	total.Padded += batch.Padded
+	total.Truncated += batch.Truncated
+	total.Collected += batch.Collected
+}
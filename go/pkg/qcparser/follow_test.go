@@ -0,0 +1,75 @@
+package qcparser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"querycraft/pkg/qcparser/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFollowUnterminatedQuote(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	output := filepath.Join(dir, "out.djson")
+
+	var b strings.Builder
+	b.WriteString("id,name,amount\n")
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&b, "%d,name%d,%d\n", i, i, i*10)
+	}
+	if err := os.WriteFile(input, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	appended := make(chan types.FollowEvent, 16)
+	done := make(chan error, 1)
+	opts := types.DefaultOptions()
+	go func() {
+		_, err := Follow(ctx, input, output, &opts, 10*time.Millisecond, func(e types.FollowEvent) {
+			appended <- e
+		})
+		done <- err
+	}()
+
+	// The rows after a line with an unbalanced quote are still records
+	rows := (<-appended).RowsWritten
+	file, err := os.OpenFile(input, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("21,\"open,210\n22,name22,220\n23,name23,230\n")
+	file.Close()
+
+	var rejected []string
+	for rows < 22 {
+		select {
+		case e := <-appended:
+			rows = e.RowsWritten
+			rejected = append(rejected, e.Errors...)
+		case <-ctx.Done():
+			t.Fatalf("only %d rows written before the timeout", rows)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"name23"`) || strings.Contains(string(data), "open") {
+		t.Errorf("output:\n%s", data)
+	}
+	if len(rejected) != 1 || !strings.Contains(rejected[0], "line 22") {
+		t.Errorf("errors = %q, want line 22 rejected", rejected)
+	}
+}
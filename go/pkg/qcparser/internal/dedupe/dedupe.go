@@ -0,0 +1,300 @@
+package dedupe
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"querycraft/pkg/qcparser/internal/spill"
+	"querycraft/pkg/qcparser/types"
+	"strings"
+)
+
+// partitions is the number of spill files keys are hashed into once the
+// memory budget is exceeded; each partition is deduplicated on its own
+const partitions = 64
+
+// Stats reports the outcome of deduplication; read it only after the row channel is closed
+type Stats struct {
+	Removed int64
+	Err     error // Spill failure; the rows after it were dropped
+}
+
+// KeyColumns returns the columns rows are compared on: opts.DedupeOn, or
+// every column when whole rows are compared. It returns nil when
+// deduplication is off.
+func KeyColumns(config *types.DetectResponse, opts *types.Options) ([]string, error) {
+	if opts.DedupeRows {
+		columns := make([]string, len(config.Columns))
+		for i, col := range config.Columns {
+			columns[i] = col.Name
+		}
+		return columns, nil
+	}
+
+	for _, name := range opts.DedupeOn {
+		found := false
+		for _, col := range config.Columns {
+			if col.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("dedupe column %q does not exist", name)
+		}
+	}
+	return opts.DedupeOn, nil
+}
+
+// Rows drops the rows whose key columns repeat an earlier row, keeping the
+// first or the last of each key in input order. Keys are held in memory up
+// to memBytes, then rows are hashed into partitions spilled to tempDir.
+func Rows(rowChan <-chan map[string]string, columns []string, keep string, memBytes int64, tempDir string) (<-chan map[string]string, *Stats) {
+	out := make(chan map[string]string, cap(rowChan))
+	d := &deduper{
+		columns:  columns,
+		keepLast: keep == types.DedupeLast,
+		budget:   memBytes,
+		tempDir:  tempDir,
+		out:      out,
+		stats:    &Stats{},
+		seen:     make(map[string]struct{}),
+		latest:   make(map[string]int64),
+	}
+
+	go func() {
+		defer close(out)
+		if err := d.run(rowChan); err != nil {
+			d.stats.Err = err
+			// Unblock the reader
+			for range rowChan {
+			}
+		}
+		if d.dir != "" {
+			os.RemoveAll(d.dir)
+		}
+		d.stats.Removed = d.in - d.emitted
+	}()
+
+	return out, d.stats
+}
+
+// deduper holds the keys seen so far, in memory until they are spilled
+type deduper struct {
+	columns  []string
+	keepLast bool
+	budget   int64
+	tempDir  string
+	out      chan<- map[string]string
+	stats    *Stats
+
+	seen     map[string]struct{} // Keep first: keys already emitted
+	latest   map[string]int64    // Keep last: last position of each key
+	buffered []*spill.Record     // Keep last: rows waiting for the end of the input
+	used     int64
+
+	dir   string // Spill directory, set once spilled
+	parts []*spill.Writer
+
+	in      int64
+	emitted int64
+}
+
+func (d *deduper) run(rowChan <-chan map[string]string) error {
+	for row := range rowChan {
+		rec := &spill.Record{Seq: d.in, Key: d.key(row), Row: row}
+		d.in++
+
+		if d.parts != nil {
+			if err := d.partition(rec); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if d.keepLast {
+			d.latest[rec.Key] = rec.Seq
+			d.buffered = append(d.buffered, rec)
+			d.used += spill.Size(row) + int64(len(rec.Key))
+		} else {
+			if _, dup := d.seen[rec.Key]; dup {
+				continue
+			}
+			d.seen[rec.Key] = struct{}{}
+			d.used += int64(len(rec.Key)) + 48
+			d.emit(row)
+		}
+
+		if d.used > d.budget {
+			if err := d.spill(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.parts == nil {
+		// Everything fit in memory
+		for _, rec := range d.buffered {
+			if d.latest[rec.Key] == rec.Seq {
+				d.emit(rec.Row)
+			}
+		}
+		return nil
+	}
+	return d.merge()
+}
+
+// key joins the key column values of a row
+func (d *deduper) key(row map[string]string) string {
+	var b strings.Builder
+	for i, name := range d.columns {
+		if i > 0 {
+			b.WriteByte(0)
+		}
+		value, ok := row[name]
+		if !ok {
+			// Missing differs from empty
+			b.WriteByte(1)
+			continue
+		}
+		b.WriteString(value)
+	}
+	return b.String()
+}
+
+func (d *deduper) emit(row map[string]string) {
+	d.emitted++
+	d.out <- row
+}
+
+// spill moves the in-memory state to the partitions: the keys already
+// emitted when keeping the first row, the buffered rows otherwise
+func (d *deduper) spill() error {
+	dir, err := os.MkdirTemp(d.tempDir, "qcparser-dedupe-*")
+	if err != nil {
+		return err
+	}
+	d.dir = dir
+
+	d.parts = make([]*spill.Writer, partitions)
+	for i := range d.parts {
+		if d.parts[i], err = spill.Create(dir); err != nil {
+			return err
+		}
+	}
+
+	// Emitted keys are markers with no row, placed before any later row
+	for key := range d.seen {
+		if err := d.partition(&spill.Record{Seq: -1, Key: key}); err != nil {
+			return err
+		}
+	}
+	for _, rec := range d.buffered {
+		if err := d.partition(rec); err != nil {
+			return err
+		}
+	}
+	d.seen, d.latest, d.buffered = nil, nil, nil
+	return nil
+}
+
+// partition appends a record to the partition of its key
+func (d *deduper) partition(rec *spill.Record) error {
+	h := fnv.New32a()
+	h.Write([]byte(rec.Key))
+	return d.parts[h.Sum32()%partitions].Write(rec)
+}
+
+// merge deduplicates each partition, then emits the surviving rows of all
+// partitions in input order
+func (d *deduper) merge() error {
+	survivors := make([]string, 0, partitions)
+	for _, part := range d.parts {
+		if err := part.Close(); err != nil {
+			return err
+		}
+		path, err := d.survivors(part.Path())
+		if err != nil {
+			return err
+		}
+		os.Remove(part.Path())
+		survivors = append(survivors, path)
+	}
+
+	return spill.Merge(survivors, func(a, b *spill.Record) bool {
+		return a.Seq < b.Seq
+	}, func(rec *spill.Record) error {
+		d.emit(rec.Row)
+		return nil
+	})
+}
+
+// survivors writes the rows of a partition that are kept to a new file,
+// in input order
+func (d *deduper) survivors(path string) (string, error) {
+	// Keeping the last row needs the last position of every key first
+	var latest map[string]int64
+	if d.keepLast {
+		latest = make(map[string]int64)
+		err := each(path, func(rec *spill.Record) error {
+			latest[rec.Key] = rec.Seq
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	out, err := spill.Create(d.dir)
+	if err != nil {
+		return "", err
+	}
+
+	seen := make(map[string]struct{})
+	err = each(path, func(rec *spill.Record) error {
+		if d.keepLast {
+			if latest[rec.Key] != rec.Seq {
+				return nil
+			}
+		} else {
+			if _, dup := seen[rec.Key]; dup {
+				return nil
+			}
+			seen[rec.Key] = struct{}{}
+			// A marker stands for a row emitted before spilling
+			if rec.Seq < 0 {
+				return nil
+			}
+		}
+		return out.Write(rec)
+	})
+	if err != nil {
+		out.Close()
+		return "", err
+	}
+	return out.Path(), out.Close()
+}
+
+// each calls fn with every record of a spill file
+func each(path string, fn func(*spill.Record) error) error {
+	reader, err := spill.Open(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		rec, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
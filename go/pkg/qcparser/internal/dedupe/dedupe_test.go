@@ -0,0 +1,154 @@
+package dedupe
+
+import (
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser/types"
+	"reflect"
+	"testing"
+)
+
+// testRows returns n rows whose "k" column repeats every keys rows
+func testRows(n, keys int) []map[string]string {
+	rows := make([]map[string]string, n)
+	for i := range rows {
+		rows[i] = map[string]string{
+			"k":   fmt.Sprintf("key%d", (i*7)%keys),
+			"seq": fmt.Sprint(i),
+		}
+	}
+	return rows
+}
+
+// expected keeps the first or last row of each key, in input order
+func expected(rows []map[string]string, keepLast bool) []string {
+	pick := make(map[string]int)
+	for i, row := range rows {
+		if _, ok := pick[row["k"]]; !ok || keepLast {
+			pick[row["k"]] = i
+		}
+	}
+	var seqs []string
+	for i, row := range rows {
+		if pick[row["k"]] == i {
+			seqs = append(seqs, row["seq"])
+		}
+	}
+	return seqs
+}
+
+func run(t *testing.T, rows []map[string]string, columns []string, keep string, memBytes int64, tempDir string) ([]string, *Stats) {
+	t.Helper()
+	in := make(chan map[string]string, 16)
+	go func() {
+		defer close(in)
+		for _, row := range rows {
+			in <- row
+		}
+	}()
+
+	out, stats := Rows(in, columns, keep, memBytes, tempDir)
+	var seqs []string
+	for row := range out {
+		seqs = append(seqs, row["seq"])
+	}
+	if stats.Err != nil {
+		t.Fatalf("Rows: %v", stats.Err)
+	}
+	return seqs, stats
+}
+
+func TestRows(t *testing.T) {
+	rows := testRows(2000, 150)
+
+	tests := []struct {
+		name     string
+		keep     string
+		memBytes int64
+	}{
+		{"first in memory", types.DedupeFirst, 1 << 20},
+		{"last in memory", types.DedupeLast, 1 << 20},
+		{"first spilled", types.DedupeFirst, 1},
+		{"last spilled", types.DedupeLast, 1},
+		{"first spilled midway", types.DedupeFirst, 2000},
+		{"last spilled midway", types.DedupeLast, 20000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			got, stats := run(t, rows, []string{"k"}, tt.keep, tt.memBytes, tempDir)
+
+			want := expected(rows, tt.keep == types.DedupeLast)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("kept %d rows %v, want %d rows %v", len(got), got, len(want), want)
+			}
+			if removed := int64(len(rows) - len(want)); stats.Removed != removed {
+				t.Errorf("Removed = %d, want %d", stats.Removed, removed)
+			}
+
+			// The spill files are deleted once the rows are out
+			entries, err := os.ReadDir(tempDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("%d spill entries left in %s", len(entries), tempDir)
+			}
+		})
+	}
+}
+
+func TestRowsMissingKey(t *testing.T) {
+	// A missing column differs from an empty one
+	rows := []map[string]string{
+		{"seq": "0"},
+		{"k": "", "seq": "1"},
+		{"seq": "2"},
+		{"k": "", "seq": "3"},
+	}
+	for _, memBytes := range []int64{1 << 20, 1} {
+		got, _ := run(t, rows, []string{"k"}, types.DedupeFirst, memBytes, t.TempDir())
+		if want := []string{"0", "1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("memBytes %d: kept %v, want %v", memBytes, got, want)
+		}
+	}
+}
+
+func TestRowsMultipleColumns(t *testing.T) {
+	// Values are joined with a separator, so "a"+"bc" differs from "ab"+"c"
+	rows := []map[string]string{
+		{"a": "a", "b": "bc", "seq": "0"},
+		{"a": "ab", "b": "c", "seq": "1"},
+		{"a": "a", "b": "bc", "seq": "2"},
+	}
+	got, stats := run(t, rows, []string{"a", "b"}, types.DedupeLast, 1, t.TempDir())
+	if want := []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+	if stats.Removed != 1 {
+		t.Errorf("Removed = %d, want 1", stats.Removed)
+	}
+}
+
+func TestKeyColumns(t *testing.T) {
+	config := &types.DetectResponse{Columns: []types.Column{{Name: "id"}, {Name: "name"}}}
+
+	columns, err := KeyColumns(config, &types.Options{DedupeRows: true})
+	if err != nil || !reflect.DeepEqual(columns, []string{"id", "name"}) {
+		t.Errorf("whole rows: %v, %v", columns, err)
+	}
+
+	columns, err = KeyColumns(config, &types.Options{DedupeOn: []string{"name"}})
+	if err != nil || !reflect.DeepEqual(columns, []string{"name"}) {
+		t.Errorf("dedupe on name: %v, %v", columns, err)
+	}
+
+	if columns, err = KeyColumns(config, &types.Options{}); err != nil || columns != nil {
+		t.Errorf("off: %v, %v", columns, err)
+	}
+
+	if _, err = KeyColumns(config, &types.Options{DedupeOn: []string{"missing"}}); err == nil {
+		t.Error("unknown dedupe column accepted")
+	}
+}
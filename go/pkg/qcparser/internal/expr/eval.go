@@ -0,0 +1,260 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Filter is a compiled boolean expression over the typed values of a row
+type Filter struct {
+	root    node
+	columns []string
+}
+
+// Columns returns the columns the filter reads
+func (f *Filter) Columns() []string {
+	return f.columns
+}
+
+// Match reports whether a row satisfies the filter. get returns a column's
+// typed value: int, float64, string, bool, []string, or nil for nulls.
+// Like SQL, conditions on nulls are unknown and do not match.
+func (f *Filter) Match(get func(column string) any) bool {
+	return f.root.eval(get) == yes
+}
+
+// truth is a three-valued logic result
+type truth int8
+
+const (
+	no truth = iota
+	yes
+	unknown
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return yes
+	}
+	return no
+}
+
+type node interface {
+	eval(get func(string) any) truth
+	visit(fn func(*operand))
+}
+
+// operand is a column reference or a literal
+type operand struct {
+	column string
+	value  any    // Literal value: float64, string, bool or nil
+	typ    string // Static type
+	text   string // Literal as written
+}
+
+func (o *operand) get(get func(string) any) any {
+	if o.column == "" {
+		return o.value
+	}
+	switch v := get(o.column).(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return v
+	}
+}
+
+func (o *operand) describe() string {
+	if o.column != "" {
+		return fmt.Sprintf("column %q (%s)", o.column, o.typ)
+	}
+	return fmt.Sprintf("%s (%s)", o.text, o.typ)
+}
+
+type orNode struct{ left, right node }
+
+func (n *orNode) eval(get func(string) any) truth {
+	l, r := n.left.eval(get), n.right.eval(get)
+	switch {
+	case l == yes || r == yes:
+		return yes
+	case l == unknown || r == unknown:
+		return unknown
+	default:
+		return no
+	}
+}
+
+func (n *orNode) visit(fn func(*operand)) { n.left.visit(fn); n.right.visit(fn) }
+
+type andNode struct{ left, right node }
+
+func (n *andNode) eval(get func(string) any) truth {
+	l := n.left.eval(get)
+	if l == no {
+		return no
+	}
+	r := n.right.eval(get)
+	switch {
+	case r == no:
+		return no
+	case l == unknown || r == unknown:
+		return unknown
+	default:
+		return yes
+	}
+}
+
+func (n *andNode) visit(fn func(*operand)) { n.left.visit(fn); n.right.visit(fn) }
+
+type notNode struct{ inner node }
+
+func (n *notNode) eval(get func(string) any) truth {
+	switch n.inner.eval(get) {
+	case yes:
+		return no
+	case no:
+		return yes
+	default:
+		return unknown
+	}
+}
+
+func (n *notNode) visit(fn func(*operand)) { n.inner.visit(fn) }
+
+type compareNode struct {
+	left  *operand
+	op    string
+	right *operand
+}
+
+func (n *compareNode) eval(get func(string) any) truth {
+	l, r := n.left.get(get), n.right.get(get)
+	if l == nil || r == nil {
+		return unknown
+	}
+
+	c, ok := compare(l, r)
+	if !ok {
+		return unknown
+	}
+	switch n.op {
+	case "=":
+		return truthOf(c == 0)
+	case "!=":
+		return truthOf(c != 0)
+	case "<":
+		return truthOf(c < 0)
+	case "<=":
+		return truthOf(c <= 0)
+	case ">":
+		return truthOf(c > 0)
+	default:
+		return truthOf(c >= 0)
+	}
+}
+
+func (n *compareNode) visit(fn func(*operand)) { fn(n.left); fn(n.right) }
+
+type inNode struct {
+	operand *operand
+	items   []*operand
+	negate  bool
+}
+
+func (n *inNode) eval(get func(string) any) truth {
+	v := n.operand.get(get)
+	if v == nil {
+		return unknown
+	}
+	for _, item := range n.items {
+		if c, ok := compare(v, item.value); ok && c == 0 {
+			return truthOf(!n.negate)
+		}
+	}
+	return truthOf(n.negate)
+}
+
+func (n *inNode) visit(fn func(*operand)) { fn(n.operand) }
+
+type likeNode struct {
+	operand *operand
+	pattern *regexp.Regexp
+	negate  bool
+}
+
+func (n *likeNode) eval(get func(string) any) truth {
+	s, ok := n.operand.get(get).(string)
+	if !ok {
+		return unknown
+	}
+	return truthOf(n.pattern.MatchString(s) != n.negate)
+}
+
+func (n *likeNode) visit(fn func(*operand)) { fn(n.operand) }
+
+type isNullNode struct {
+	operand *operand
+	negate  bool
+}
+
+func (n *isNullNode) eval(get func(string) any) truth {
+	return truthOf((n.operand.get(get) == nil) != n.negate)
+}
+
+func (n *isNullNode) visit(fn func(*operand)) { fn(n.operand) }
+
+// truthNode is a boolean column or literal used as a condition
+type truthNode struct{ operand *operand }
+
+func (n *truthNode) eval(get func(string) any) truth {
+	b, ok := n.operand.get(get).(bool)
+	if !ok {
+		return unknown
+	}
+	return truthOf(b)
+}
+
+func (n *truthNode) visit(fn func(*operand)) { fn(n.operand) }
+
+// compare orders two values of the same kind
+func compare(a, b any) (int, bool) {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		if x == y {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
@@ -0,0 +1,166 @@
+package expr
+
+import (
+	"querycraft/pkg/qcparser/types"
+	"strings"
+	"testing"
+)
+
+var testColumns = []types.Column{
+	{Name: "name", Type: "TEXT"},
+	{Name: "amount", Type: "DOUBLE"},
+	{Name: "qty", Type: "INT"},
+	{Name: "day", Type: "DATE"},
+	{Name: "active", Type: "BOOLEAN"},
+	{Name: "tags", Type: "TEXT[]"},
+	{Name: "first name", Type: "TEXT"},
+}
+
+// testRow holds typed values like the writer passes them to Match
+var testRow = map[string]any{
+	"name":       "Alice",
+	"amount":     12.5,
+	"qty":        3,
+	"day":        "2024-03-05",
+	"active":     true,
+	"tags":       []string{"a", "b"},
+	"first name": "Al",
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expr string
+		row  map[string]any
+		want bool
+	}{
+		// Typed comparisons
+		{"amount > 10", testRow, true},
+		{"amount >= 12.5", testRow, true},
+		{"amount < 12.5", testRow, false},
+		{"qty = 3", testRow, true},
+		{"qty == 3 AND qty <> 4", testRow, true},
+		{"qty != 3", testRow, false},
+		{"name = 'Alice'", testRow, true},
+		{"name < 'Bob'", testRow, true},
+		{"day >= '2024-03-01'", testRow, true},
+		{"day < '2024-03-05 00:00:00'", testRow, false},
+		{"day = '2024-03-05T10:00:00Z'", testRow, true},
+		{"active = TRUE", testRow, true},
+		{"active", testRow, true},
+		{"NOT active", testRow, false},
+		{`"first name" = 'Al'`, testRow, true},
+
+		// Text columns compare with numbers as written
+		{"name = 42", map[string]any{"name": "42"}, true},
+
+		// LIKE
+		{"name LIKE 'A%'", testRow, true},
+		{"name LIKE 'a%'", testRow, false},
+		{"name LIKE 'Al_ce'", testRow, true},
+		{"name LIKE 'Al_'", testRow, false},
+		{"name NOT LIKE '%z%'", testRow, true},
+		{"name LIKE '100%'", map[string]any{"name": "100% sure"}, true},
+		{"name LIKE 'a.c'", map[string]any{"name": "abc"}, false},
+		{"name LIKE '%'", map[string]any{"name": "line\nbreak"}, true},
+		{"day LIKE '2024-03-%'", testRow, true},
+
+		// IN
+		{"name IN ('Bob', 'Alice')", testRow, true},
+		{"name IN ('Bob')", testRow, false},
+		{"name NOT IN ('Bob')", testRow, true},
+		{"qty IN (1, 2, 3)", testRow, true},
+		{"qty NOT IN (1, 2, 3)", testRow, false},
+		{"day IN ('2024-03-05')", testRow, true},
+
+		// IS NULL
+		{"name IS NULL", map[string]any{}, true},
+		{"name IS NOT NULL", map[string]any{}, false},
+		{"name IS NULL", testRow, false},
+		{"name IS NOT NULL", testRow, true},
+
+		// Conditions on nulls are unknown and do not match, not even negated
+		{"amount > 10", map[string]any{}, false},
+		{"NOT amount > 10", map[string]any{}, false},
+		{"name NOT IN ('Bob')", map[string]any{}, false},
+		{"name NOT LIKE 'B%'", map[string]any{}, false},
+		{"amount > 10 OR qty = 3", map[string]any{"qty": 3}, true},
+		{"amount > 10 AND qty = 3", map[string]any{"qty": 3}, false},
+		{"NOT (amount > 10 AND qty = 4)", map[string]any{"qty": 3}, true},
+
+		// Precedence: AND binds tighter than OR
+		{"qty = 1 OR qty = 3 AND name = 'Bob'", testRow, false},
+		{"(qty = 1 OR qty = 3) AND name = 'Alice'", testRow, true},
+		{"qty = 3 OR amount < 0 AND name = 'Bob'", testRow, true},
+	}
+
+	for _, tt := range tests {
+		filter, err := Compile(tt.expr, testColumns)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.expr, err)
+			continue
+		}
+		get := func(column string) any { return tt.row[column] }
+		if got := filter.Match(get); got != tt.want {
+			t.Errorf("%q on %v = %v, want %v", tt.expr, tt.row, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"missing = 1", `unknown column "missing" at 0`},
+		{"qty = 'three'", "cannot compare column \"qty\" (number) with three (text) at 4"},
+		{"day = 'tomorrow'", `"tomorrow" at 4 is not a date`},
+		{"active > TRUE", "bool values only support = and != at 7"},
+		{"qty = NULL", "comparison with NULL at 4 is never true, use IS NULL"},
+		{"qty IN (1, NULL)", "NULL is not allowed in an IN list at 11, use IS NULL"},
+		{"name IN ('a', qty)", "IN list items must be literals at 14"},
+		{"qty IN (1, 'x')", "cannot compare column \"qty\" (number) with x (text) at 11"},
+		{"qty LIKE '1%'", `LIKE needs a text operand, column "qty" (number) is number`},
+		{"name", `column "name" (text) is not a boolean condition`},
+		{"name IS 'x'", "expected NULL at 8"},
+		{"name NOT = 'x'", "expected IN or LIKE at 9"},
+		{"name = 'x' qty", `unexpected "qty" at 11`},
+		{"(qty = 1", "expected ')' at 8"},
+		{"qty IN 1", "expected '(' after IN at 7"},
+		{"name = 'open", "unterminated quote starting at 7"},
+		{"qty ! 1", "unexpected '!' at 4"},
+		{"qty = ", "unexpected end of expression"},
+		{"qty = 1.2.3", `invalid number "1.2.3" at 6`},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.expr, testColumns)
+		if err == nil {
+			t.Errorf("Compile(%q) succeeded, want error %q", tt.expr, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%q) error = %q, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// Check only parses, columns and types are not known yet
+	if err := Check("missing = 'x' AND other IN (1, 2)"); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	if err := Check("a = "); err == nil {
+		t.Error("Check accepted an incomplete expression")
+	}
+}
+
+func TestColumns(t *testing.T) {
+	filter, err := Compile("qty > 1 AND (name LIKE 'A%' OR qty IS NULL) AND day IN ('2024-01-01')", testColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(filter.Columns(), ",")
+	if want := "qty,name,day"; got != want {
+		t.Errorf("Columns() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenKind identifies the lexical class of a token
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp     // = == != <> < <= > >=
+	tokLParen // (
+	tokRParen // )
+	tokComma
+	tokKeyword // AND OR NOT IN LIKE IS NULL TRUE FALSE
+)
+
+type token struct {
+	kind tokenKind
+	text string // Keywords are upper-cased, quoted identifiers and strings unquoted
+	pos  int    // Byte offset in the source
+}
+
+var keywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IN": true, "LIKE": true,
+	"IS": true, "NULL": true, "TRUE": true, "FALSE": true,
+}
+
+// lex splits an expression into tokens
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+
+	for i := 0; i < len(runes); {
+		ch := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+			continue
+
+		case ch == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: start})
+			i++
+		case ch == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: start})
+			i++
+		case ch == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: start})
+			i++
+
+		case strings.ContainsRune("=!<>", ch):
+			op := string(ch)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<>", "<=", ">=":
+					op = two
+				}
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected '!' at %d", start)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: start})
+			i += len(op)
+
+		case ch == '\'' || ch == '"':
+			// Strings are single-quoted, identifiers double-quoted; quotes are doubled to escape
+			text, next, err := lexQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			kind := tokString
+			if ch == '"' {
+				kind = tokIdent
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: start})
+			i = next
+
+		case unicode.IsDigit(ch) || ch == '.' || (ch == '-' && i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.')):
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || strings.ContainsRune(".eE", runes[i]) ||
+				((runes[i] == '-' || runes[i] == '+') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(runes[start:i]), pos: start})
+
+		case unicode.IsLetter(ch) || ch == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			word := string(runes[start:i])
+			if upper := strings.ToUpper(word); keywords[upper] {
+				tokens = append(tokens, token{kind: tokKeyword, text: upper, pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, text: word, pos: start})
+			}
+
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", ch, start)
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(runes)}), nil
+}
+
+// lexQuoted reads a quoted string starting at runes[i] and returns it with the index after it
+func lexQuoted(runes []rune, i int) (string, int, error) {
+	quote := runes[i]
+	var b strings.Builder
+
+	for j := i + 1; j < len(runes); j++ {
+		if runes[j] != quote {
+			b.WriteRune(runes[j])
+			continue
+		}
+		if j+1 < len(runes) && runes[j+1] == quote {
+			b.WriteRune(quote)
+			j++
+			continue
+		}
+		return b.String(), j + 1, nil
+	}
+
+	return "", 0, fmt.Errorf("unterminated quote starting at %d", i)
+}
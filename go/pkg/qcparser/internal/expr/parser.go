@@ -0,0 +1,389 @@
+package expr
+
+import (
+	"fmt"
+	"querycraft/pkg/qcparser/types"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Static types of operands
+const (
+	typeNumber = "number"
+	typeText   = "text"
+	typeDate   = "date"
+	typeBool   = "bool"
+	typeList   = "list"
+	typeNull   = "null"
+)
+
+// operandType returns the static type of a column type
+func operandType(columnType string) string {
+	switch columnType {
+	case "INT", "DOUBLE":
+		return typeNumber
+	case "DATE", "TIMESTAMP":
+		return typeDate
+	case "BOOLEAN":
+		return typeBool
+	case "TEXT[]":
+		return typeList
+	default:
+		return typeText
+	}
+}
+
+// parser is a recursive descent parser over the tokens of one expression:
+//
+//	expr    := and { OR and }
+//	and     := not { AND not }
+//	not     := NOT not | pred
+//	pred    := '(' expr ')' | operand [ cmp operand | [NOT] IN '(' operand {, operand} ')'
+//	           | [NOT] LIKE string | IS [NOT] NULL ]
+//	operand := column | "quoted column" | number | 'string' | TRUE | FALSE | NULL
+type parser struct {
+	tokens  []token
+	pos     int
+	columns map[string]string // Column name to static type
+}
+
+// Compile parses an expression and checks it against the columns it filters
+func Compile(src string, columns []types.Column) (*Filter, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, columns: make(map[string]string, len(columns))}
+	for _, col := range columns {
+		p.columns[col.Name] = operandType(col.Type)
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+
+	return &Filter{root: root, columns: referencedColumns(root)}, nil
+}
+
+// Check parses an expression without checking column names or types
+func Check(src string) error {
+	tokens, err := lex(src)
+	if err != nil {
+		return err
+	}
+
+	p := &parser{tokens: tokens}
+	if _, err := p.parseOr(); err != nil {
+		return err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+	return nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is the given keyword
+func (p *parser) accept(keyword string) bool {
+	if tok := p.peek(); tok.kind == tokKeyword && tok.text == keyword {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, fmt.Errorf("expected %s at %d", what, tok.pos)
+	}
+	return tok, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept("NOT") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{inner: inner}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (node, error) {
+	if p.peek().kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokOp:
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return p.comparison(left, tok, right)
+
+	case p.accept("IS"):
+		negate := p.accept("NOT")
+		if !p.accept("NULL") {
+			return nil, fmt.Errorf("expected NULL at %d", p.peek().pos)
+		}
+		return &isNullNode{operand: left, negate: negate}, nil
+
+	case tok.kind == tokKeyword && (tok.text == "NOT" || tok.text == "IN" || tok.text == "LIKE"):
+		negate := p.accept("NOT")
+		if p.accept("IN") {
+			return p.parseIn(left, negate)
+		}
+		if p.accept("LIKE") {
+			return p.parseLike(left, negate)
+		}
+		return nil, fmt.Errorf("expected IN or LIKE at %d", p.peek().pos)
+	}
+
+	// A lone operand must be boolean
+	if p.columns != nil && left.typ != typeBool {
+		return nil, fmt.Errorf("%s is not a boolean condition", left.describe())
+	}
+	return &truthNode{operand: left}, nil
+}
+
+func (p *parser) parseIn(left *operand, negate bool) (node, error) {
+	if _, err := p.expect(tokLParen, "'(' after IN"); err != nil {
+		return nil, err
+	}
+
+	in := &inNode{operand: left, negate: negate}
+	for {
+		tok := p.peek()
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if item.column != "" {
+			return nil, fmt.Errorf("IN list items must be literals at %d", tok.pos)
+		}
+		if item.typ == typeNull {
+			return nil, fmt.Errorf("NULL is not allowed in an IN list at %d, use IS NULL", tok.pos)
+		}
+		if err := p.unify(left, item, tok); err != nil {
+			return nil, err
+		}
+		in.items = append(in.items, item)
+
+		if p.peek().kind == tokComma {
+			p.next()
+			continue
+		}
+		if _, err := p.expect(tokRParen, "')' after IN list"); err != nil {
+			return nil, err
+		}
+		return in, nil
+	}
+}
+
+func (p *parser) parseLike(left *operand, negate bool) (node, error) {
+	tok, err := p.expect(tokString, "a quoted pattern after LIKE")
+	if err != nil {
+		return nil, err
+	}
+	if p.columns != nil && left.typ != typeText && left.typ != typeDate {
+		return nil, fmt.Errorf("LIKE needs a text operand, %s is %s", left.describe(), left.typ)
+	}
+	return &likeNode{operand: left, pattern: likePattern(tok.text), negate: negate}, nil
+}
+
+func (p *parser) parseOperand() (*operand, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokIdent:
+		if p.columns == nil {
+			return &operand{column: tok.text}, nil
+		}
+		typ, ok := p.columns[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q at %d", tok.text, tok.pos)
+		}
+		return &operand{column: tok.text, typ: typ}, nil
+	case tokString:
+		return &operand{value: tok.text, typ: typeText, text: tok.text}, nil
+	case tokNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", tok.text, tok.pos)
+		}
+		return &operand{value: v, typ: typeNumber, text: tok.text}, nil
+	case tokKeyword:
+		switch tok.text {
+		case "TRUE", "FALSE":
+			return &operand{value: tok.text == "TRUE", typ: typeBool, text: tok.text}, nil
+		case "NULL":
+			return &operand{typ: typeNull, text: tok.text}, nil
+		}
+	}
+	if tok.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+}
+
+// comparison checks that both sides of a comparison can be compared
+func (p *parser) comparison(left *operand, op token, right *operand) (node, error) {
+	if left.typ == typeNull || right.typ == typeNull {
+		return nil, fmt.Errorf("comparison with NULL at %d is never true, use IS NULL", op.pos)
+	}
+	if err := p.unify(left, right, op); err != nil {
+		return nil, err
+	}
+
+	operator := op.text
+	switch operator {
+	case "==":
+		operator = "="
+	case "<>":
+		operator = "!="
+	}
+	if p.columns != nil && (left.typ == typeBool || left.typ == typeList) && operator != "=" && operator != "!=" {
+		return nil, fmt.Errorf("%s values only support = and != at %d", left.typ, op.pos)
+	}
+	return &compareNode{left: left, op: operator, right: right}, nil
+}
+
+// unify coerces a literal to the type of the column it is compared with
+func (p *parser) unify(a, b *operand, at token) error {
+	if p.columns == nil {
+		return nil
+	}
+	if a.column == "" && b.column != "" {
+		a, b = b, a
+	}
+	if a.typ == b.typ {
+		return nil
+	}
+
+	if a.column != "" && b.column == "" {
+		switch {
+		case a.typ == typeText:
+			// Text columns compare with the literal as written
+			b.value, b.typ = b.text, typeText
+			return nil
+		case a.typ == typeDate && b.typ == typeText:
+			date, ok := parseDate(b.text)
+			if !ok {
+				return fmt.Errorf("%q at %d is not a date", b.text, at.pos)
+			}
+			b.value, b.typ = date, typeDate
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot compare %s with %s at %d", a.describe(), b.describe(), at.pos)
+}
+
+// referencedColumns returns the columns an expression reads
+func referencedColumns(root node) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	root.visit(func(o *operand) {
+		if o.column != "" && !seen[o.column] {
+			seen[o.column] = true
+			columns = append(columns, o.column)
+		}
+	})
+	return columns
+}
+
+// dateLayouts are the literal formats accepted for DATE and TIMESTAMP columns
+var dateLayouts = []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339}
+
+// parseDate normalizes a date literal to the writer's output format
+func parseDate(s string) (string, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	return "", false
+}
+
+// likePattern compiles a LIKE pattern, where % matches any run and _ any single character
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^(?s:")
+	for _, ch := range pattern {
+		switch ch {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	b.WriteString(")$")
+	return regexp.MustCompile(b.String())
+}
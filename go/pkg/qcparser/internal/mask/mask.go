@@ -0,0 +1,197 @@
+package mask
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/types"
+	"strings"
+	"unicode"
+)
+
+// redacted replaces the values of redacted columns
+const redacted = "***"
+
+// Masker masks the values of some columns of every row
+type Masker struct {
+	columns map[string]func(string) string
+	config  *types.DetectResponse
+}
+
+// Check reports errors in mask rules before any input is read; columns are
+// checked once they are known
+func Check(rules []types.MaskRule, salt string) error {
+	for _, rule := range rules {
+		if rule.Column == "" {
+			return errors.New("mask column is required")
+		}
+		if !types.IsMaskStrategy(rule.Strategy) {
+			return fmt.Errorf("unknown mask strategy %q for column %s, use redact, hash, last or tokenize", rule.Strategy, rule.Column)
+		}
+		if rule.Strategy == types.MaskLast && rule.Keep <= 0 {
+			return fmt.Errorf("mask last of column %s needs a positive number of characters to keep", rule.Column)
+		}
+		if (rule.Strategy == types.MaskHash || rule.Strategy == types.MaskTokenize) && salt == "" {
+			// Unsalted hashes of emails or phone numbers are reversed by guessing
+			return fmt.Errorf("mask %s of column %s needs a salt", rule.Strategy, rule.Column)
+		}
+	}
+	return nil
+}
+
+// New resolves the rules against the columns of the rows; masked columns
+// are written as TEXT. It returns nil when there are no rules.
+func New(config *types.DetectResponse, rules []types.MaskRule, salt string) (*Masker, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if err := Check(rules, salt); err != nil {
+		return nil, err
+	}
+
+	masked := *config
+	masked.Columns = append([]types.Column(nil), config.Columns...)
+	m := &Masker{columns: make(map[string]func(string) string, len(rules)), config: &masked}
+	for _, rule := range rules {
+		i := columnIndex(masked.Columns, rule.Column)
+		if i < 0 {
+			return nil, fmt.Errorf("mask column %q does not exist", rule.Column)
+		}
+		masked.Columns[i] = types.Column{Name: rule.Column, Type: "TEXT", Nullable: masked.Columns[i].Nullable}
+		m.columns[rule.Column] = strategy(rule, salt)
+	}
+
+	// The preview is kept in the manifest, so it is masked too
+	masked.Preview.Data = make([]map[string]string, len(config.Preview.Data))
+	for i, row := range config.Preview.Data {
+		copied := make(map[string]string, len(row))
+		for k, v := range row {
+			copied[k] = v
+		}
+		m.Apply(copied)
+		masked.Preview.Data[i] = copied
+	}
+	return m, nil
+}
+
+// Config returns the detection result with the masked columns as TEXT and
+// a masked preview
+func (m *Masker) Config() *types.DetectResponse {
+	return m.config
+}
+
+// Apply masks a row in place; null values stay null
+func (m *Masker) Apply(row map[string]string) {
+	for name, fn := range m.columns {
+		if value, ok := row[name]; ok && !detector.IsNullValue(value) {
+			row[name] = fn(value)
+		}
+	}
+}
+
+// Rows masks every row
+func Rows(rowChan <-chan map[string]string, m *Masker) <-chan map[string]string {
+	out := make(chan map[string]string, cap(rowChan))
+	go func() {
+		defer close(out)
+		for row := range rowChan {
+			m.Apply(row)
+			out <- row
+		}
+	}()
+	return out
+}
+
+// strategy returns the function masking the values of a rule's column
+func strategy(rule types.MaskRule, salt string) func(string) string {
+	switch rule.Strategy {
+	case types.MaskHash:
+		return func(value string) string {
+			return hex.EncodeToString(digest(salt, value))
+		}
+	case types.MaskLast:
+		return func(value string) string {
+			return keepLast(value, rule.Keep)
+		}
+	case types.MaskTokenize:
+		return func(value string) string {
+			return tokenize(salt, value)
+		}
+	default:
+		return func(string) string {
+			return redacted
+		}
+	}
+}
+
+// digest is the salted HMAC-SHA256 of a value
+func digest(salt, value string) []byte {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// keepLast replaces every character but the last n with *; values of n
+// characters or less are masked entirely
+func keepLast(value string, n int) string {
+	runes := []rune(value)
+	if len(runes) <= n {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-n) + string(runes[len(runes)-n:])
+}
+
+// tokenize replaces each digit with a digit and each letter with a letter
+// of the same case, derived from the salted digest of the whole value, so
+// the token keeps the layout of the value and equal values get equal tokens
+func tokenize(salt, value string) string {
+	stream := newKeyStream(salt, value)
+	var b strings.Builder
+	for _, c := range value {
+		switch {
+		case c >= '0' && c <= '9':
+			b.WriteByte('0' + stream.next()%10)
+		case unicode.IsUpper(c):
+			b.WriteByte('A' + stream.next()%26)
+		case unicode.IsLower(c):
+			b.WriteByte('a' + stream.next()%26)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// keyStream yields pseudo-random bytes from a value's digest, extended by
+// hashing the previous block
+type keyStream struct {
+	salt  string
+	block []byte
+	pos   int
+}
+
+func newKeyStream(salt, value string) *keyStream {
+	return &keyStream{salt: salt, block: digest(salt, value)}
+}
+
+func (s *keyStream) next() byte {
+	if s.pos == len(s.block) {
+		s.block = digest(s.salt, string(s.block))
+		s.pos = 0
+	}
+	b := s.block[s.pos]
+	s.pos++
+	return b
+}
+
+func columnIndex(columns []types.Column, name string) int {
+	for i, col := range columns {
+		if col.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -3,20 +3,20 @@ package reader
 import (
 	"bufio"
 	"fmt"
-	"os"
 	"querycraft/pkg/qcparser/detector"
 	"querycraft/pkg/qcparser/types"
+	"strconv"
 	"strings"
 )
 
-func readCSV(filepath string, config *types.DetectResponse) (<-chan map[string]string, <-chan error) {
+func readCSV(open opener, config *types.DetectResponse, opts *types.Options, stats *Stats) (<-chan map[string]string, <-chan error) {
 	readedRows := make(chan map[string]string)
 	errChan := make(chan error)
 
 	go func() {
 		defer close(readedRows)
 		defer close(errChan)
-		file, err := os.Open(filepath)
+		file, err := open()
 
 		if err != nil {
 			errChan <- err
@@ -27,39 +27,74 @@ func readCSV(filepath string, config *types.DetectResponse) (<-chan map[string]s
 
 		scanner := bufio.NewScanner(file)
 		scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024)
-		rowID := 0
+		lineNo := 0
 		delimiterRune := []rune(config.Delimiter.Delimiter)[0]
 		skippedHeader := false
 
+		// Hold back the last FooterRows lines so the footer is never emitted
+		pending := make([]string, 0, config.FooterRows+1)
+
 		for scanner.Scan() {
-			rowID++
-			line := scanner.Text()
+			lineNo++
+
+			// Skip preamble lines before the table
+			if lineNo <= config.SkipRows {
+				continue
+			}
+
+			text := scanner.Text()
+			if lineNo == 1 {
+				text = strings.TrimPrefix(text, "\uFEFF")
+			}
+			pending = append(pending, text)
+			if len(pending) <= config.FooterRows {
+				continue
+			}
+			line := pending[0]
+			pending = pending[1:]
+			rowID := lineNo - config.FooterRows
+
 			if config.Comment != nil && strings.HasPrefix(strings.TrimSpace(line), *config.Comment) {
 				continue
 			}
 			fields, invalid := detector.SplitLineFields(line, delimiterRune)
 			if invalid {
-				errChan <- fmt.Errorf("invalid line %d: %s", rowID, line)
-				continue
-			}
-			if len(fields) != len(config.Columns) {
-				errChan <- fmt.Errorf("invalid line %d: expected %d fields, got %d",
-					rowID, len(config.Columns), len(fields))
+				errChan <- &LineError{Line: rowID, Kind: ErrUnterminatedQuote, Reason: lineReason(line, opts)}
 				continue
 			}
 
-			// Skip header row if present
+			// Skip header row if present, before the ragged row policy so a
+			// ragged header is neither counted nor taken for a data row
 			if config.HasHeader && !skippedHeader {
 				skippedHeader = true
 				continue
 			}
 
+			fieldCount := len(fields)
+			fields, extra, ok := detector.FitFields(fields, config.FieldCount, opts.RaggedRows, &stats.Ragged)
+			if !ok {
+				errChan <- &LineError{Line: rowID, Kind: ErrFieldCount,
+					Reason: fmt.Sprintf("expected %d fields, got %d", config.FieldCount, fieldCount)}
+				continue
+			}
+
 			row := make(map[string]string)
 			for i, field := range fields {
 				if i < len(config.Columns) {
 					row[config.Columns[i].Name] = strings.TrimSpace(field)
 				}
 			}
+			if len(extra) > 0 {
+				row[types.ExtraColumn] = detector.EncodeExtra(extra)
+			}
+			if opts.LineColumn != "" {
+				row[opts.LineColumn] = strconv.Itoa(rowID)
+			}
+			if name, ok := nullViolation(row, config.Columns); ok {
+				errChan <- &LineError{Line: rowID, Kind: ErrNullValue, Column: name,
+					Reason: fmt.Sprintf("column %q must not be null", name)}
+				continue
+			}
 			readedRows <- row
 		}
 
@@ -70,3 +105,26 @@ func readCSV(filepath string, config *types.DetectResponse) (<-chan map[string]s
 
 	return readedRows, errChan
 }
+
+// lineReason is the reason given for a line that cannot be split: the line
+// itself, unless columns are masked. Errors are kept in the manifest and the
+// cache, where the raw values of masked columns must not appear.
+func lineReason(line string, opts *types.Options) string {
+	if len(opts.Masks) > 0 {
+		return "unterminated quote"
+	}
+	return line
+}
+
+// nullViolation returns the first non-nullable column holding a null value
+func nullViolation(row map[string]string, columns []types.Column) (string, bool) {
+	for _, col := range columns {
+		if col.Nullable == nil || *col.Nullable {
+			continue
+		}
+		if value, ok := row[col.Name]; !ok || detector.IsNullValue(value) {
+			return col.Name, true
+		}
+	}
+	return "", false
+}
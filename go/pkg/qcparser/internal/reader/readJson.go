@@ -2,6 +2,6 @@ package reader
 
 import "querycraft/pkg/qcparser/types"
 
-func readJSON(filepath string, config *types.DetectResponse) (<-chan map[string]string, <-chan error) {
+func readJSON(open opener, config *types.DetectResponse) (<-chan map[string]string, <-chan error) {
 	return nil, nil
 }
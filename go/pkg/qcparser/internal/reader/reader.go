@@ -1,16 +1,67 @@
 package reader
 
-import "querycraft/pkg/qcparser/types"
+import (
+	"fmt"
+	"io"
+	"os"
+	"querycraft/pkg/qcparser/types"
+)
+
+// Stats collects counters filled in while reading; read them only after the row channel is closed
+type Stats struct {
+	Ragged types.RaggedCounts
+}
+
+// Kinds of LineError
+const (
+	ErrUnterminatedQuote = "unterminated_quote"
+	ErrFieldCount        = "field_count"
+	ErrNullValue         = "null_value"
+)
+
+// LineError reports a source line that could not be turned into a row
+type LineError struct {
+	Line   int    // 1-based line number
+	Kind   string // One of the Err* kinds
+	Column string // Offending column, when the error is about one
+	Reason string
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("invalid line %d: %s", e.Line, e.Reason)
+}
+
+// opener returns the input to read from; the reader closes it when done
+type opener func() (io.ReadCloser, error)
+
+// Read streams the rows of a file
+func Read(filepath string, config *types.DetectResponse, opts *types.Options) (<-chan map[string]string, <-chan error, *Stats) {
+	return read(func() (io.ReadCloser, error) {
+		return os.Open(filepath)
+	}, config, opts)
+}
+
+// ReadFrom streams the rows of an already open input
+func ReadFrom(r io.Reader, config *types.DetectResponse, opts *types.Options) (<-chan map[string]string, <-chan error, *Stats) {
+	return read(func() (io.ReadCloser, error) {
+		return io.NopCloser(r), nil
+	}, config, opts)
+}
+
+func read(open opener, config *types.DetectResponse, opts *types.Options) (<-chan map[string]string, <-chan error, *Stats) {
+	stats := &Stats{}
 
-func Read(filepath string, config *types.DetectResponse) (<-chan map[string]string, <-chan error) {
 	switch config.Format {
 	case "json":
-		return readJSON(filepath, config)
+		rows, errs := readJSON(open, config)
+		return rows, errs, stats
 	case "csv":
-		return readCSV(filepath, config)
+		rows, errs := readCSV(open, config, opts, stats)
+		return rows, errs, stats
 	case "jsonl":
-		return readJSONL(filepath, config)
+		rows, errs := readJSONL(open, config)
+		return rows, errs, stats
 	default:
-		return nil, nil
+		return nil, nil, stats
 	}
 }
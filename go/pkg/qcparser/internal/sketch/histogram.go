@@ -0,0 +1,106 @@
+package sketch
+
+import (
+	"math"
+	"sort"
+)
+
+// histogramCentroids is the number of centroids kept by the streaming histogram
+const histogramCentroids = 128
+
+// Histogram approximates the distribution of a numeric stream by merging
+// the closest centroids once the limit is reached (Ben-Haim & Tom-Tov)
+type Histogram struct {
+	centroids []centroid
+}
+
+// Bin is an equal-width histogram bucket
+type Bin struct {
+	Low   float64
+	High  float64
+	Count int64
+}
+
+type centroid struct {
+	value float64
+	count int64
+}
+
+// NewHistogram creates an empty streaming histogram
+func NewHistogram() *Histogram {
+	return &Histogram{centroids: make([]centroid, 0, histogramCentroids+1)}
+}
+
+// Add records a value
+func (h *Histogram) Add(value float64) {
+	i := sort.Search(len(h.centroids), func(i int) bool { return h.centroids[i].value >= value })
+	if i < len(h.centroids) && h.centroids[i].value == value {
+		h.centroids[i].count++
+		return
+	}
+
+	h.centroids = append(h.centroids, centroid{})
+	copy(h.centroids[i+1:], h.centroids[i:])
+	h.centroids[i] = centroid{value: value, count: 1}
+
+	if len(h.centroids) > histogramCentroids {
+		h.mergeClosest()
+	}
+}
+
+// mergeClosest merges the two neighbouring centroids with the smallest gap
+func (h *Histogram) mergeClosest() {
+	best := 0
+	gap := math.Inf(1)
+	for i := 0; i+1 < len(h.centroids); i++ {
+		if d := h.centroids[i+1].value - h.centroids[i].value; d < gap {
+			gap = d
+			best = i
+		}
+	}
+
+	a, b := h.centroids[best], h.centroids[best+1]
+	count := a.count + b.count
+	h.centroids[best] = centroid{
+		value: (a.value*float64(a.count) + b.value*float64(b.count)) / float64(count),
+		count: count,
+	}
+	h.centroids = append(h.centroids[:best+1], h.centroids[best+2:]...)
+}
+
+// Bins spreads the centroids over n equal-width bins between min and max
+func (h *Histogram) Bins(n int, min, max float64) []Bin {
+	if n <= 0 || len(h.centroids) == 0 {
+		return nil
+	}
+	if min == max {
+		return []Bin{{Low: min, High: max, Count: h.total()}}
+	}
+
+	width := (max - min) / float64(n)
+	bins := make([]Bin, n)
+	for i := range bins {
+		bins[i].Low = min + float64(i)*width
+		bins[i].High = min + float64(i+1)*width
+	}
+
+	for _, c := range h.centroids {
+		i := int((c.value - min) / width)
+		if i >= n {
+			i = n - 1
+		}
+		if i < 0 {
+			i = 0
+		}
+		bins[i].Count += c.count
+	}
+	return bins
+}
+
+func (h *Histogram) total() int64 {
+	var total int64
+	for _, c := range h.centroids {
+		total += c.count
+	}
+	return total
+}
@@ -0,0 +1,69 @@
+package sketch
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// hllPrecision is the number of index bits, giving 2^14 registers (~0.8% error)
+const hllPrecision = 14
+
+// HyperLogLog estimates the number of distinct values in a stream
+type HyperLogLog struct {
+	registers []uint8
+}
+
+// NewHyperLogLog creates an empty HyperLogLog sketch
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{registers: make([]uint8, 1<<hllPrecision)}
+}
+
+// Add records a value
+func (h *HyperLogLog) Add(value string) {
+	x := Hash(value)
+	idx := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Count returns the estimated number of distinct values
+func (h *HyperLogLog) Count() uint64 {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1.0 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Small range correction with linear counting
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// Hash returns a well-mixed 64-bit hash of a string
+func Hash(value string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(value))
+	return mix64(f.Sum64())
+}
+
+// mix64 is the splitmix64 finalizer, spreading FNV output over all bits
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,40 @@
+package sketch
+
+import "math"
+
+// Moments tracks count, mean and variance with Welford's algorithm
+type Moments struct {
+	Count int64
+	Min   float64
+	Max   float64
+	mean  float64
+	m2    float64
+}
+
+// Add records a value
+func (m *Moments) Add(value float64) {
+	if m.Count == 0 || value < m.Min {
+		m.Min = value
+	}
+	if m.Count == 0 || value > m.Max {
+		m.Max = value
+	}
+
+	m.Count++
+	delta := value - m.mean
+	m.mean += delta / float64(m.Count)
+	m.m2 += delta * (value - m.mean)
+}
+
+// Mean returns the arithmetic mean
+func (m *Moments) Mean() float64 {
+	return m.mean
+}
+
+// StdDev returns the population standard deviation
+func (m *Moments) StdDev() float64 {
+	if m.Count == 0 {
+		return 0
+	}
+	return math.Sqrt(m.m2 / float64(m.Count))
+}
@@ -0,0 +1,110 @@
+package sketch
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// topKFactor sizes the counter table relative to k to keep estimates accurate
+const topKFactor = 10
+
+// TopK tracks the most frequent values using the Space-Saving algorithm
+type TopK struct {
+	k        int
+	capacity int
+	counters map[string]*counter
+	heap     counterHeap
+}
+
+// ValueCount is a value with the number of occurrences it is guaranteed to have
+type ValueCount struct {
+	Value string
+	Count int64
+}
+
+type counter struct {
+	value string
+	count int64
+	err   int64 // Overestimation inherited from the evicted value
+	index int
+}
+
+// NewTopK creates a tracker for the k most frequent values
+func NewTopK(k int) *TopK {
+	return &TopK{
+		k:        k,
+		capacity: k * topKFactor,
+		counters: make(map[string]*counter),
+	}
+}
+
+// Add records one occurrence of value
+func (t *TopK) Add(value string) {
+	if t.k <= 0 {
+		return
+	}
+
+	if c, ok := t.counters[value]; ok {
+		c.count++
+		heap.Fix(&t.heap, c.index)
+		return
+	}
+
+	if len(t.counters) < t.capacity {
+		c := &counter{value: value, count: 1}
+		t.counters[value] = c
+		heap.Push(&t.heap, c)
+		return
+	}
+
+	// Replace the least frequent value, inheriting its count
+	min := t.heap[0]
+	delete(t.counters, min.value)
+	min.value = value
+	min.err = min.count
+	min.count++
+	t.counters[value] = min
+	heap.Fix(&t.heap, min.index)
+}
+
+// Values returns the k most frequent values, highest count first
+func (t *TopK) Values() []ValueCount {
+	values := make([]ValueCount, 0, len(t.counters))
+	for _, c := range t.counters {
+		values = append(values, ValueCount{Value: c.value, Count: c.count - c.err})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > t.k {
+		values = values[:t.k]
+	}
+	return values
+}
+
+// counterHeap is a min-heap of counters by count
+type counterHeap []*counter
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x any) {
+	c := x.(*counter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
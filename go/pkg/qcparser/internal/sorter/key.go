@@ -0,0 +1,146 @@
+package sorter
+
+import (
+	"encoding/binary"
+	"math"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+)
+
+// Kinds of key column comparison
+const (
+	compareText = iota
+	compareInt
+	compareFloat
+	compareTime
+	compareBool
+)
+
+// keyColumn is a sort column with the comparison of its detected type
+type keyColumn struct {
+	name    string
+	compare int
+	layout  string // Known date layout, dateparse is only used without one
+	locale  string // Separators and month names of the values
+	desc    bool
+}
+
+func newKeyColumn(col types.Column, desc bool) keyColumn {
+	key := keyColumn{name: col.Name, locale: col.Locale, desc: desc}
+	switch col.Type {
+	case "INT":
+		key.compare = compareInt
+	case "DOUBLE":
+		key.compare = compareFloat
+	case "DATE", "TIMESTAMP":
+		key.compare = compareTime
+		if col.Format != "" {
+			key.layout = util.StrftimeToLayout(col.Format)
+		}
+	case "BOOLEAN":
+		key.compare = compareBool
+	default:
+		key.compare = compareText
+	}
+	return key
+}
+
+// encodeKey encodes the key columns of a row so that comparing keys as
+// strings compares the typed values. Each value is prefixed with 0, nulls
+// and values that do not parse with 1, so they sort last in both orders.
+func encodeKey(columns []keyColumn, row map[string]string) string {
+	var buf []byte
+	for _, col := range columns {
+		value, ok := row[col.name]
+		var encoded []byte
+		if ok && !detector.IsNullValue(value) {
+			encoded, ok = col.encode(value)
+		} else {
+			ok = false
+		}
+
+		if !ok {
+			buf = append(buf, 1)
+			continue
+		}
+		buf = append(buf, 0)
+		if col.desc {
+			for i := range encoded {
+				encoded[i] = ^encoded[i]
+			}
+		}
+		buf = append(buf, encoded...)
+	}
+	return string(buf)
+}
+
+// encode returns the order-preserving bytes of a value
+func (c keyColumn) encode(value string) ([]byte, bool) {
+	switch c.compare {
+	case compareInt:
+		n, ok := detector.ParseInteger(value, c.locale)
+		if !ok {
+			return nil, false
+		}
+		return binary.BigEndian.AppendUint64(nil, uint64(n)^(1<<63)), true
+	case compareFloat:
+		f, ok := detector.ParseNumber(value, c.locale)
+		if !ok || math.IsNaN(f) {
+			return nil, false
+		}
+		return encodeFloat(f), true
+	case compareTime:
+		t, ok := c.parseTime(value)
+		if !ok {
+			return nil, false
+		}
+		buf := binary.BigEndian.AppendUint64(nil, uint64(t.Unix())^(1<<63))
+		return binary.BigEndian.AppendUint32(buf, uint32(t.Nanosecond())), true
+	case compareBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, false
+		}
+		if b {
+			return []byte{1}, true
+		}
+		return []byte{0}, true
+	default:
+		return encodeText(value), true
+	}
+}
+
+func (c keyColumn) parseTime(value string) (time.Time, bool) {
+	value = detector.NormalizeMonths(value, c.locale)
+	if c.layout != "" {
+		t, err := time.Parse(c.layout, value)
+		return t, err == nil
+	}
+	t, err := dateparse.ParseAny(value)
+	return t, err == nil
+}
+
+// encodeFloat maps a float to bytes ordered like the float
+func encodeFloat(f float64) []byte {
+	bits := math.Float64bits(f)
+	if bits>>63 == 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return binary.BigEndian.AppendUint64(nil, bits)
+}
+
+// encodeText escapes zero bytes and terminates the text, so that no key is
+// a prefix of another and text compares bytewise
+func encodeText(s string) []byte {
+	buf := make([]byte, 0, len(s)+2)
+	buf = append(buf, strings.ReplaceAll(s, "\x00", "\x00\xff")...)
+	return append(buf, 0, 0)
+}
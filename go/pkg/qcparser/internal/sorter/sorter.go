@@ -0,0 +1,191 @@
+package sorter
+
+import (
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser/internal/spill"
+	"querycraft/pkg/qcparser/types"
+	"sort"
+)
+
+// maxFanIn is the number of sorted runs merged at once; more runs are
+// first merged into larger ones
+const maxFanIn = 64
+
+// Stats reports the outcome of sorting; read it only after the row channel is closed
+type Stats struct {
+	Err error // Spill failure; no rows are emitted after it
+}
+
+// Order is a row order resolved against the detected columns
+type Order struct {
+	columns []keyColumn
+}
+
+// NewOrder resolves the sort keys against the detected columns; it returns
+// nil when there are no keys
+func NewOrder(config *types.DetectResponse, keys []types.SortKey) (*Order, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	order := &Order{columns: make([]keyColumn, 0, len(keys))}
+	for _, key := range keys {
+		col, ok := findColumn(config.Columns, key.Column)
+		if !ok {
+			return nil, fmt.Errorf("sort column %q does not exist", key.Column)
+		}
+		order.columns = append(order.columns, newKeyColumn(col, key.Desc))
+	}
+	return order, nil
+}
+
+// Rows orders rows by the key columns, keeping the input order of equal
+// rows. Rows are sorted in memory up to memBytes, then as sorted runs
+// spilled to tempDir and merged.
+func Rows(rowChan <-chan map[string]string, order *Order, memBytes int64, tempDir string) (<-chan map[string]string, *Stats) {
+	out := make(chan map[string]string, cap(rowChan))
+	s := &sorter{columns: order.columns, budget: memBytes, tempDir: tempDir, out: out, stats: &Stats{}}
+
+	go func() {
+		defer close(out)
+		if err := s.run(rowChan); err != nil {
+			s.stats.Err = err
+			// Unblock the reader
+			for range rowChan {
+			}
+		}
+		if s.dir != "" {
+			os.RemoveAll(s.dir)
+		}
+	}()
+
+	return out, s.stats
+}
+
+// sorter buffers rows until the memory budget is reached
+type sorter struct {
+	columns []keyColumn
+	budget  int64
+	tempDir string
+	out     chan<- map[string]string
+	stats   *Stats
+
+	buffered []*spill.Record
+	used     int64
+
+	dir  string // Spill directory, set once spilled
+	runs []string
+}
+
+func (s *sorter) run(rowChan <-chan map[string]string) error {
+	var seq int64
+	for row := range rowChan {
+		rec := &spill.Record{Seq: seq, Key: encodeKey(s.columns, row), Row: row}
+		seq++
+		s.buffered = append(s.buffered, rec)
+		s.used += spill.Size(row) + int64(len(rec.Key))
+
+		if s.used > s.budget {
+			if err := s.spill(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.runs == nil {
+		// Everything fit in memory
+		s.sortBuffered()
+		for _, rec := range s.buffered {
+			s.out <- rec.Row
+		}
+		return nil
+	}
+
+	if err := s.spill(); err != nil {
+		return err
+	}
+	return s.merge()
+}
+
+// sortBuffered sorts the buffered rows, equal keys keep the input order
+func (s *sorter) sortBuffered() {
+	sort.Slice(s.buffered, func(i, j int) bool {
+		return less(s.buffered[i], s.buffered[j])
+	})
+}
+
+// spill writes the buffered rows as a sorted run
+func (s *sorter) spill() error {
+	if s.dir == "" {
+		dir, err := os.MkdirTemp(s.tempDir, "qcparser-sort-*")
+		if err != nil {
+			return err
+		}
+		s.dir = dir
+	}
+
+	s.sortBuffered()
+	run, err := spill.Create(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, rec := range s.buffered {
+		if err := run.Write(rec); err != nil {
+			run.Close()
+			return err
+		}
+	}
+	if err := run.Close(); err != nil {
+		return err
+	}
+
+	s.runs = append(s.runs, run.Path())
+	s.buffered, s.used = nil, 0
+	return nil
+}
+
+// merge merges the sorted runs into the output, in several passes when
+// there are too many runs to open at once
+func (s *sorter) merge() error {
+	for len(s.runs) > maxFanIn {
+		merged, err := spill.Create(s.dir)
+		if err != nil {
+			return err
+		}
+		batch := s.runs[:maxFanIn]
+		err = spill.Merge(batch, less, merged.Write)
+		if closeErr := merged.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		for _, path := range batch {
+			os.Remove(path)
+		}
+		s.runs = append(s.runs[maxFanIn:], merged.Path())
+	}
+
+	return spill.Merge(s.runs, less, func(rec *spill.Record) error {
+		s.out <- rec.Row
+		return nil
+	})
+}
+
+// less orders records by key, then by input position
+func less(a, b *spill.Record) bool {
+	if a.Key != b.Key {
+		return a.Key < b.Key
+	}
+	return a.Seq < b.Seq
+}
+
+func findColumn(columns []types.Column, name string) (types.Column, bool) {
+	for _, col := range columns {
+		if col.Name == name {
+			return col, true
+		}
+	}
+	return types.Column{}, false
+}
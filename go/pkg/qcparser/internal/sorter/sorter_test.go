@@ -0,0 +1,237 @@
+package sorter
+
+import (
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+var testConfig = &types.DetectResponse{Columns: []types.Column{
+	{Name: "n", Type: "INT"},
+	{Name: "x", Type: "DOUBLE"},
+	{Name: "s", Type: "TEXT"},
+	{Name: "d", Type: "DATE", Format: "%d/%m/%Y"},
+	{Name: "b", Type: "BOOLEAN"},
+	{Name: "seq", Type: "INT"},
+}}
+
+// sortRows runs rows through Rows and returns the seq column of the output
+func sortRows(t *testing.T, rows []map[string]string, keys []types.SortKey, memBytes int64, tempDir string) []string {
+	t.Helper()
+	order, err := NewOrder(testConfig, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := make(chan map[string]string, 16)
+	go func() {
+		defer close(in)
+		for _, row := range rows {
+			in <- row
+		}
+	}()
+
+	out, stats := Rows(in, order, memBytes, tempDir)
+	var seqs []string
+	for row := range out {
+		seqs = append(seqs, row["seq"])
+	}
+	if stats.Err != nil {
+		t.Fatalf("Rows: %v", stats.Err)
+	}
+	return seqs
+}
+
+// numberedRows adds a seq column holding the input position of each row
+func numberedRows(rows ...map[string]string) []map[string]string {
+	for i, row := range rows {
+		row["seq"] = strconv.Itoa(i)
+	}
+	return rows
+}
+
+func TestRows(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []map[string]string
+		keys []types.SortKey
+		want []string
+	}{
+		{
+			name: "numbers compare as numbers",
+			rows: numberedRows(
+				map[string]string{"n": "10"},
+				map[string]string{"n": "9"},
+				map[string]string{"n": "-3"},
+				map[string]string{"n": "100"},
+			),
+			keys: []types.SortKey{{Column: "n"}},
+			want: []string{"2", "1", "0", "3"},
+		},
+		{
+			name: "descending",
+			rows: numberedRows(
+				map[string]string{"x": "1.5"},
+				map[string]string{"x": "-2.25"},
+				map[string]string{"x": "10"},
+				map[string]string{"x": "0"},
+			),
+			keys: []types.SortKey{{Column: "x", Desc: true}},
+			want: []string{"2", "0", "3", "1"},
+		},
+		{
+			name: "stable for equal keys",
+			rows: numberedRows(
+				map[string]string{"s": "b"},
+				map[string]string{"s": "a"},
+				map[string]string{"s": "b"},
+				map[string]string{"s": "a"},
+				map[string]string{"s": "b"},
+			),
+			keys: []types.SortKey{{Column: "s"}},
+			want: []string{"1", "3", "0", "2", "4"},
+		},
+		{
+			name: "stable for equal keys descending",
+			rows: numberedRows(
+				map[string]string{"s": "a"},
+				map[string]string{"s": "b"},
+				map[string]string{"s": "a"},
+				map[string]string{"s": "b"},
+			),
+			keys: []types.SortKey{{Column: "s", Desc: true}},
+			want: []string{"1", "3", "0", "2"},
+		},
+		{
+			name: "nulls and unparsed values last ascending",
+			rows: numberedRows(
+				map[string]string{"n": ""},
+				map[string]string{"n": "2"},
+				map[string]string{},
+				map[string]string{"n": "NULL"},
+				map[string]string{"n": "abc"},
+				map[string]string{"n": "1"},
+			),
+			keys: []types.SortKey{{Column: "n"}},
+			want: []string{"5", "1", "0", "2", "3", "4"},
+		},
+		{
+			name: "nulls last descending",
+			rows: numberedRows(
+				map[string]string{"n": ""},
+				map[string]string{"n": "1"},
+				map[string]string{"n": "2"},
+			),
+			keys: []types.SortKey{{Column: "n", Desc: true}},
+			want: []string{"2", "1", "0"},
+		},
+		{
+			name: "dates use the detected format",
+			rows: numberedRows(
+				map[string]string{"d": "02/01/2024"},
+				map[string]string{"d": "01/02/2024"},
+				map[string]string{"d": "31/12/2023"},
+				map[string]string{"d": "2024-01-01"},
+			),
+			keys: []types.SortKey{{Column: "d"}},
+			want: []string{"2", "0", "1", "3"},
+		},
+		{
+			name: "booleans",
+			rows: numberedRows(
+				map[string]string{"b": "true"},
+				map[string]string{"b": "false"},
+				map[string]string{"b": "true"},
+			),
+			keys: []types.SortKey{{Column: "b"}},
+			want: []string{"1", "0", "2"},
+		},
+		{
+			name: "text prefixes sort first",
+			rows: numberedRows(
+				map[string]string{"s": "ab"},
+				map[string]string{"s": "a"},
+				map[string]string{"s": "a\x00b"},
+				map[string]string{"s": ""},
+			),
+			keys: []types.SortKey{{Column: "s"}},
+			want: []string{"1", "2", "0", "3"},
+		},
+		{
+			name: "later keys break ties",
+			rows: numberedRows(
+				map[string]string{"s": "a", "n": "2"},
+				map[string]string{"s": "b", "n": "1"},
+				map[string]string{"s": "a", "n": "1"},
+				map[string]string{"s": "b", "n": "2"},
+			),
+			keys: []types.SortKey{{Column: "s"}, {Column: "n", Desc: true}},
+			want: []string{"0", "2", "3", "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		// The same order in memory and with every row in its own run
+		for _, memBytes := range []int64{1 << 20, 1} {
+			t.Run(fmt.Sprintf("%s/%d", tt.name, memBytes), func(t *testing.T) {
+				got := sortRows(t, tt.rows, tt.keys, memBytes, t.TempDir())
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("order %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestRowsMultiPassMerge(t *testing.T) {
+	// Tiny memBytes spills every row as its own run, far more than maxFanIn
+	const n = maxFanIn*3 + 17
+	rows := make([]map[string]string, n)
+	for i := range rows {
+		rows[i] = map[string]string{
+			"n":   strconv.Itoa((i * 37) % 50),
+			"seq": strconv.Itoa(i),
+		}
+	}
+
+	want := make([]map[string]string, n)
+	copy(want, rows)
+	sort.SliceStable(want, func(i, j int) bool {
+		a, _ := strconv.Atoi(want[i]["n"])
+		b, _ := strconv.Atoi(want[j]["n"])
+		return a > b
+	})
+	wantSeqs := make([]string, n)
+	for i, row := range want {
+		wantSeqs[i] = row["seq"]
+	}
+
+	tempDir := t.TempDir()
+	got := sortRows(t, rows, []types.SortKey{{Column: "n", Desc: true}}, 1, tempDir)
+	if !reflect.DeepEqual(got, wantSeqs) {
+		t.Errorf("order %v, want %v", got, wantSeqs)
+	}
+
+	// The runs are deleted once the rows are out
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d spill entries left in %s", len(entries), tempDir)
+	}
+}
+
+func TestNewOrder(t *testing.T) {
+	order, err := NewOrder(testConfig, nil)
+	if order != nil || err != nil {
+		t.Errorf("no keys: %v, %v", order, err)
+	}
+	if _, err := NewOrder(testConfig, []types.SortKey{{Column: "missing"}}); err == nil {
+		t.Error("unknown sort column accepted")
+	}
+}
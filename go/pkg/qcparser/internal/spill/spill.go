@@ -0,0 +1,166 @@
+package spill
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+)
+
+// Record is a row written to a spill file with its position in the input
+type Record struct {
+	Seq int64
+	Key string
+	Row map[string]string
+}
+
+// Size estimates the memory held by a row
+func Size(row map[string]string) int64 {
+	size := int64(48) // Map header
+	for k, v := range row {
+		size += int64(len(k)+len(v)) + 32
+	}
+	return size
+}
+
+// Writer appends records to a temporary file
+type Writer struct {
+	file  *os.File
+	buf   *bufio.Writer
+	enc   *gob.Encoder
+	Count int64
+}
+
+// Create creates a spill file in dir
+func Create(dir string) (*Writer, error) {
+	file, err := os.CreateTemp(dir, "spill-*")
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriterSize(file, 256<<10)
+	return &Writer{file: file, buf: buf, enc: gob.NewEncoder(buf)}, nil
+}
+
+// Write appends a record
+func (w *Writer) Write(rec *Record) error {
+	w.Count++
+	return w.enc.Encode(rec)
+}
+
+// Path returns the path of the spill file
+func (w *Writer) Path() string {
+	return w.file.Name()
+}
+
+// Close flushes and closes the file, which stays on disk
+func (w *Writer) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Reader reads the records of a spill file in write order
+type Reader struct {
+	file *os.File
+	dec  *gob.Decoder
+}
+
+// Open opens a spill file written by a Writer
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{file: file, dec: gob.NewDecoder(bufio.NewReaderSize(file, 256<<10))}, nil
+}
+
+// Next returns the next record, or io.EOF after the last one
+func (r *Reader) Next() (*Record, error) {
+	// Decode into a new record, gob merges maps into existing ones
+	rec := &Record{}
+	if err := r.dec.Decode(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Close closes the file
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// Merge reads spill files whose records are each ordered by less and calls
+// emit with all their records in that order
+func Merge(paths []string, less func(a, b *Record) bool, emit func(*Record) error) error {
+	h := &mergeHeap{less: less}
+	defer func() {
+		for _, item := range h.items {
+			item.reader.Close()
+		}
+	}()
+
+	for _, path := range paths {
+		reader, err := Open(path)
+		if err != nil {
+			return err
+		}
+		rec, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			reader.Close()
+			continue
+		}
+		if err != nil {
+			reader.Close()
+			return err
+		}
+		h.items = append(h.items, &mergeItem{rec: rec, reader: reader})
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		item := h.items[0]
+		if err := emit(item.rec); err != nil {
+			return err
+		}
+
+		rec, err := item.reader.Next()
+		if errors.Is(err, io.EOF) {
+			item.reader.Close()
+			heap.Pop(h)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		item.rec = rec
+		heap.Fix(h, 0)
+	}
+	return nil
+}
+
+// mergeItem is the current record of one merged file
+type mergeItem struct {
+	rec    *Record
+	reader *Reader
+}
+
+// mergeHeap orders the current records of the merged files
+type mergeHeap struct {
+	items []*mergeItem
+	less  func(a, b *Record) bool
+}
+
+func (h *mergeHeap) Len() int           { return len(h.items) }
+func (h *mergeHeap) Less(i, j int) bool { return h.less(h.items[i].rec, h.items[j].rec) }
+func (h *mergeHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *mergeHeap) Push(x any)         { h.items = append(h.items, x.(*mergeItem)) }
+
+func (h *mergeHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
@@ -0,0 +1,354 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/types"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Pipeline is a list of transform steps resolved against the input columns
+type Pipeline struct {
+	steps  []func(row map[string]string)
+	config *types.DetectResponse
+}
+
+// Check reports the errors of a transform spec that do not depend on the
+// input columns
+func Check(steps []types.TransformStep) error {
+	for i, step := range steps {
+		if _, err := check(step); err != nil {
+			return stepError(i, step, err)
+		}
+	}
+	return nil
+}
+
+// Compile resolves the steps against the detected columns and computes the
+// columns of the transformed rows; it returns nil when there are no steps
+func Compile(config *types.DetectResponse, steps []types.TransformStep) (*Pipeline, error) {
+	if len(steps) == 0 {
+		return nil, nil
+	}
+
+	s := &schema{columns: append([]types.Column(nil), config.Columns...)}
+	p := &Pipeline{}
+	for i, step := range steps {
+		fn, err := compile(s, step)
+		if err != nil {
+			return nil, stepError(i, step, err)
+		}
+		p.steps = append(p.steps, fn)
+	}
+
+	// The preview is kept in the manifest, so it shows transformed rows
+	transformed := *config
+	transformed.Columns = s.columns
+	transformed.Preview.Data = make([]map[string]string, len(config.Preview.Data))
+	for i, row := range config.Preview.Data {
+		copied := make(map[string]string, len(row))
+		for k, v := range row {
+			copied[k] = v
+		}
+		p.Apply(copied)
+		transformed.Preview.Data[i] = copied
+	}
+	p.config = &transformed
+	return p, nil
+}
+
+// Config returns the detection result with the columns and preview rows of
+// the transformed rows
+func (p *Pipeline) Config() *types.DetectResponse {
+	return p.config
+}
+
+// Apply transforms a row in place
+func (p *Pipeline) Apply(row map[string]string) {
+	for _, step := range p.steps {
+		step(row)
+	}
+}
+
+// Rows applies the pipeline to every row
+func Rows(rowChan <-chan map[string]string, p *Pipeline) <-chan map[string]string {
+	out := make(chan map[string]string, cap(rowChan))
+	go func() {
+		defer close(out)
+		for row := range rowChan {
+			p.Apply(row)
+			out <- row
+		}
+	}()
+	return out
+}
+
+func stepError(i int, step types.TransformStep, err error) error {
+	return fmt.Errorf("transform %d (%s): %w", i+1, step.Op, err)
+}
+
+// check validates the fields of a step and compiles its pattern
+func check(step types.TransformStep) (*regexp.Regexp, error) {
+	switch step.Op {
+	case types.TransformRename:
+		if step.Column == "" || step.As == "" {
+			return nil, errors.New("column and as are required")
+		}
+	case types.TransformCast:
+		if step.Column == "" {
+			return nil, errors.New("column is required")
+		}
+		if !types.IsColumnType(step.Type) {
+			return nil, fmt.Errorf("unknown type %q", step.Type)
+		}
+	case types.TransformRegexExtract, types.TransformReplace:
+		if step.Column == "" || step.Pattern == "" {
+			return nil, errors.New("column and pattern are required")
+		}
+		re, err := regexp.Compile(step.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		if step.Group != nil && (*step.Group < 0 || *step.Group > re.NumSubexp()) {
+			return nil, fmt.Errorf("pattern has no group %d", *step.Group)
+		}
+		return re, nil
+	case types.TransformSplit:
+		if step.Column == "" || step.Separator == "" || len(step.Into) == 0 {
+			return nil, errors.New("column, separator and into are required")
+		}
+	case types.TransformConcat, types.TransformCoalesce:
+		if len(step.Columns) == 0 || step.As == "" {
+			return nil, errors.New("columns and as are required")
+		}
+	case types.TransformLowercase, types.TransformParsePercentage, types.TransformParseAmount:
+		if step.Column == "" {
+			return nil, errors.New("column is required")
+		}
+	case types.TransformConstant:
+		if step.As == "" {
+			return nil, errors.New("as is required")
+		}
+		if step.Type != "" && !types.IsColumnType(step.Type) {
+			return nil, fmt.Errorf("unknown type %q", step.Type)
+		}
+	default:
+		return nil, errors.New("unknown operation")
+	}
+	return nil, nil
+}
+
+// compile checks a step against the current columns, updates them with
+// the columns it writes and returns the function applying it to a row
+func compile(s *schema, step types.TransformStep) (func(row map[string]string), error) {
+	re, err := check(step)
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []string
+	if step.Column != "" {
+		inputs = append(inputs, step.Column)
+	}
+	inputs = append(inputs, step.Columns...)
+	for _, name := range inputs {
+		if s.index(name) < 0 {
+			return nil, fmt.Errorf("column %q does not exist", name)
+		}
+	}
+
+	// Steps without as write their column in place
+	out := step.As
+	if out == "" {
+		out = step.Column
+	}
+
+	switch step.Op {
+	case types.TransformRename:
+		if step.As != step.Column && s.index(step.As) >= 0 {
+			return nil, fmt.Errorf("column %q already exists", step.As)
+		}
+		s.columns[s.index(step.Column)].Name = step.As
+		return func(row map[string]string) {
+			if value, ok := row[step.Column]; ok {
+				delete(row, step.Column)
+				row[step.As] = value
+			}
+		}, nil
+
+	case types.TransformCast:
+		col := &s.columns[s.index(step.Column)]
+		col.Type, col.Format = step.Type, step.Format
+		// Values are parsed as the new type when written
+		return func(map[string]string) {}, nil
+
+	case types.TransformRegexExtract:
+		group := 0
+		if step.Group != nil {
+			group = *step.Group
+		} else if re.NumSubexp() > 0 {
+			group = 1
+		}
+		s.set(types.Column{Name: out, Type: "TEXT"}, step.Column)
+		return func(row map[string]string) {
+			value, ok := row[step.Column]
+			if !ok {
+				delete(row, out)
+				return
+			}
+			match := re.FindStringSubmatchIndex(value)
+			if match == nil || match[2*group] < 0 {
+				delete(row, out)
+				return
+			}
+			row[out] = value[match[2*group]:match[2*group+1]]
+		}, nil
+
+	case types.TransformSplit:
+		for i := len(step.Into) - 1; i >= 0; i-- {
+			s.set(types.Column{Name: step.Into[i], Type: "TEXT"}, step.Column)
+		}
+		return func(row map[string]string) {
+			value, ok := row[step.Column]
+			var parts []string
+			if ok {
+				// The last column keeps the rest of the value
+				parts = strings.SplitN(value, step.Separator, len(step.Into))
+			}
+			for i, name := range step.Into {
+				if i < len(parts) {
+					row[name] = parts[i]
+				} else {
+					delete(row, name)
+				}
+			}
+		}, nil
+
+	case types.TransformConcat:
+		s.set(types.Column{Name: out, Type: "TEXT"}, "")
+		return func(row map[string]string) {
+			// Null values are skipped, like concat_ws
+			var values []string
+			for _, name := range step.Columns {
+				if value, ok := row[name]; ok && !detector.IsNullValue(value) {
+					values = append(values, value)
+				}
+			}
+			if len(values) == 0 {
+				delete(row, out)
+				return
+			}
+			row[out] = strings.Join(values, step.Separator)
+		}, nil
+
+	case types.TransformReplace:
+		s.set(types.Column{Name: out, Type: "TEXT"}, step.Column)
+		return func(row map[string]string) {
+			value, ok := row[step.Column]
+			if !ok {
+				delete(row, out)
+				return
+			}
+			row[out] = re.ReplaceAllString(value, step.With)
+		}, nil
+
+	case types.TransformLowercase:
+		col := s.columns[s.index(step.Column)]
+		col.Name = out
+		s.set(col, step.Column)
+		return func(row map[string]string) {
+			if value, ok := row[step.Column]; ok {
+				row[out] = strings.ToLower(value)
+			} else {
+				delete(row, out)
+			}
+		}, nil
+
+	case types.TransformParsePercentage, types.TransformParseAmount:
+		parse := detector.ParseAmount
+		if step.Op == types.TransformParsePercentage {
+			parse = detector.ParsePercentage
+		}
+		s.set(types.Column{Name: out, Type: "DOUBLE"}, step.Column)
+		return func(row map[string]string) {
+			// Values that do not parse become null
+			value, ok := row[step.Column]
+			if !ok {
+				delete(row, out)
+				return
+			}
+			v, ok := parse(value)
+			if !ok {
+				delete(row, out)
+				return
+			}
+			row[out] = strconv.FormatFloat(v, 'f', -1, 64)
+		}, nil
+
+	case types.TransformCoalesce:
+		s.set(s.common(out, step.Columns), "")
+		return func(row map[string]string) {
+			for _, name := range step.Columns {
+				if value, ok := row[name]; ok && !detector.IsNullValue(value) {
+					row[out] = value
+					return
+				}
+			}
+			delete(row, out)
+		}, nil
+
+	default: // Constant
+		col := types.Column{Name: out, Type: step.Type, Format: step.Format}
+		if col.Type == "" {
+			col.Type = "TEXT"
+		}
+		s.set(col, "")
+		return func(row map[string]string) {
+			row[out] = step.Value
+		}, nil
+	}
+}
+
+// schema is the list of columns as transformed by the steps so far
+type schema struct {
+	columns []types.Column
+}
+
+func (s *schema) index(name string) int {
+	for i, col := range s.columns {
+		if col.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// set replaces the column of the same name, or inserts it after the column
+// named after, or last when after is empty
+func (s *schema) set(col types.Column, after string) {
+	if i := s.index(col.Name); i >= 0 {
+		s.columns[i] = col
+		return
+	}
+	i := len(s.columns)
+	if after != "" {
+		i = s.index(after) + 1
+	}
+	s.columns = append(s.columns[:i], append([]types.Column{col}, s.columns[i:]...)...)
+}
+
+// common returns a column named name with the type shared by the columns,
+// or TEXT when they differ
+func (s *schema) common(name string, columns []string) types.Column {
+	first := s.columns[s.index(columns[0])]
+	for _, other := range columns[1:] {
+		col := s.columns[s.index(other)]
+		if col.Type != first.Type || col.Format != first.Format {
+			return types.Column{Name: name, Type: "TEXT"}
+		}
+	}
+	return types.Column{Name: name, Type: first.Type, Format: first.Format}
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	"context"
+	"io"
+	"querycraft/pkg/qcparser/types"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressReader wraps a reader, counting the bytes read and failing with
+// the context's error once it is cancelled
+type ProgressReader struct {
+	ctx context.Context
+	r   io.Reader
+	n   atomic.Int64
+
+	mu  sync.Mutex
+	err error // First read error other than io.EOF
+}
+
+// NewProgressReader returns a ProgressReader over r
+func NewProgressReader(ctx context.Context, r io.Reader) *ProgressReader {
+	return &ProgressReader{ctx: ctx, r: r}
+}
+
+func (p *ProgressReader) Read(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		p.fail(err)
+		return 0, err
+	}
+	n, err := p.r.Read(b)
+	p.n.Add(int64(n))
+	if err != nil && err != io.EOF {
+		p.fail(err)
+	}
+	return n, err
+}
+
+func (p *ProgressReader) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// Err returns the first read error other than io.EOF, if any
+func (p *ProgressReader) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// BytesRead returns the number of bytes read so far; safe for concurrent use
+func (p *ProgressReader) BytesRead() int64 {
+	return p.n.Load()
+}
+
+// progressInterval is the time between two progress updates
+const progressInterval = 250 * time.Millisecond
+
+// TrackRows forwards rows while counting them, calling fn periodically with
+// the rows seen and the bytes read from input. The returned function stops
+// the updates once the rows are consumed and sends a final one.
+func TrackRows(rowChan <-chan map[string]string, input *ProgressReader, total int64, fn types.ProgressFunc) (<-chan map[string]string, func()) {
+	if fn == nil {
+		return rowChan, func() {}
+	}
+
+	var rows atomic.Int64
+	out := make(chan map[string]string, cap(rowChan))
+	go func() {
+		defer close(out)
+		for row := range rowChan {
+			rows.Add(1)
+			out <- row
+		}
+	}()
+
+	report := func() {
+		fn(types.Progress{BytesRead: input.BytesRead(), TotalBytes: total, Rows: rows.Load()})
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				report()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return out, func() {
+		close(done)
+		<-stopped
+		report()
+	}
+}
@@ -2,8 +2,13 @@ package util
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"hash"
 	"io"
+	"strings"
 )
 
 // GetLines reads lines from a reader up to maxBytes or maxLine limit
@@ -53,3 +58,86 @@ func readLine(reader *bufio.Reader) (string, int64, error) {
 
 	return string(buff), int64(len(buff)), nil
 }
+
+// GetTailLines reads the complete lines contained in the last maxBytes of a file
+func GetTailLines(r io.ReaderAt, size int64, maxBytes int64) ([]string, error) {
+	offset := size - maxBytes
+	if offset < 0 {
+		offset = 0
+	}
+
+	buf := make([]byte, size-offset)
+	n, err := r.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	// Drop the partial line the window starts in
+	if offset > 0 {
+		idx := bytes.IndexByte(buf, '\n')
+		if idx < 0 {
+			return nil, nil
+		}
+		buf = buf[idx+1:]
+	}
+
+	text := strings.TrimSuffix(string(buf), "\n")
+	if text == "" {
+		return nil, nil
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, nil
+}
+
+// CountLines counts the lines in a reader, including a final unterminated line
+func CountLines(r io.Reader) (int, error) {
+	buf := make([]byte, 1<<20)
+	count := 0
+	var last byte
+
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			count += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return count, err
+		}
+	}
+
+	if last != 0 && last != '\n' {
+		count++
+	}
+	return count, nil
+}
+
+// HashReader computes the SHA-256 of everything read through it
+type HashReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+// NewHashReader returns a HashReader over r
+func NewHashReader(r io.Reader) *HashReader {
+	return &HashReader{r: r, h: sha256.New()}
+}
+
+func (h *HashReader) Read(b []byte) (int, error) {
+	n, err := h.r.Read(b)
+	h.h.Write(b[:n])
+	return n, err
+}
+
+// Sum returns the hex digest of the bytes read so far
+func (h *HashReader) Sum() string {
+	return hex.EncodeToString(h.h.Sum(nil))
+}
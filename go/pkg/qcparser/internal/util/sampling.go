@@ -0,0 +1,131 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/rand"
+	"querycraft/pkg/qcparser/types"
+	"sort"
+)
+
+// headShare is the part of the budget reservoir sampling keeps for the head,
+// so headers and preambles are always sampled
+const headShare = 4
+
+// Sample reads detection lines from a seekable input of the given size with
+// the given strategy, within a budget of maxBytes. The head of the input is
+// always the first region, and lines keep their order in the input.
+func Sample(r io.ReadSeeker, size int64, strategy string, regions int, maxBytes int64, maxLine int) ([]string, []types.SampleRegion, error) {
+	if maxBytes <= 0 || size <= maxBytes {
+		strategy = types.SampleHead
+	}
+
+	switch strategy {
+	case types.SampleStratified:
+		return sampleStratified(r, size, regions, maxBytes, maxLine)
+	case types.SampleReservoir:
+		return sampleReservoir(r, size, maxBytes, maxLine)
+	default:
+		lines, n, err := GetLines(r, maxBytes, maxLine)
+		return lines, []types.SampleRegion{{Offset: 0, Bytes: n, Lines: len(lines)}}, err
+	}
+}
+
+// sampleStratified reads equal windows at evenly spaced offsets, the first
+// at the start of the input and the last ending at its end
+func sampleStratified(r io.ReadSeeker, size int64, regions int, maxBytes int64, maxLine int) ([]string, []types.SampleRegion, error) {
+	if regions < 2 {
+		regions = 2
+	}
+	window := maxBytes / int64(regions)
+	step := (size - window) / int64(regions-1)
+
+	var lines []string
+	var sampled []types.SampleRegion
+	for i := 0; i < regions; i++ {
+		offset := int64(i) * step
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+
+		reader := bufio.NewReader(r)
+		var skipped int64
+		// Windows past the start begin mid-line, realign on the next line
+		if offset > 0 {
+			partial, err := reader.ReadString('\n')
+			if err != nil {
+				continue
+			}
+			skipped = int64(len(partial))
+		}
+
+		regionLines, n, err := GetLines(reader, window, maxLine)
+		if err != nil {
+			return nil, nil, err
+		}
+		lines = append(lines, regionLines...)
+		// Line lengths exclude the newlines
+		sampled = append(sampled, types.SampleRegion{Offset: offset + skipped, Bytes: n + int64(len(regionLines)), Lines: len(regionLines)})
+	}
+
+	return lines, sampled, nil
+}
+
+// sampleReservoir keeps the head of the input and a uniform sample of the
+// remaining lines, read in one pass over the whole input
+func sampleReservoir(r io.ReadSeeker, size int64, maxBytes int64, maxLine int) ([]string, []types.SampleRegion, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	reader := bufio.NewReaderSize(r, 1<<20)
+
+	head, headBytes, err := GetLines(reader, maxBytes/headShare, maxLine)
+	if err != nil {
+		return nil, nil, err
+	}
+	headBytes += int64(len(head)) // Line lengths exclude the newlines
+
+	// Size the reservoir from the head's average line length
+	capacity := len(head)*(headShare-1) + 1
+	if headBytes > 0 {
+		capacity = int((maxBytes - headBytes) * int64(len(head)) / headBytes)
+	}
+
+	type sampledLine struct {
+		index int
+		text  string
+	}
+	reservoir := make([]sampledLine, 0, capacity)
+	rng := rand.New(rand.NewSource(size))
+	var restBytes int64
+
+	for seen := 0; ; seen++ {
+		line, n, err := readLine(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, nil, err
+		}
+		restBytes += n + 1
+
+		// Algorithm R: the i-th line replaces a random slot with probability capacity/i
+		if len(reservoir) < capacity {
+			reservoir = append(reservoir, sampledLine{seen, line})
+		} else if j := rng.Intn(seen + 1); j < capacity {
+			reservoir[j] = sampledLine{seen, line}
+		}
+	}
+
+	sort.Slice(reservoir, func(i, j int) bool { return reservoir[i].index < reservoir[j].index })
+	lines := head
+	for _, l := range reservoir {
+		lines = append(lines, l.text)
+	}
+
+	return lines, []types.SampleRegion{
+		{Offset: 0, Bytes: headBytes, Lines: len(head)},
+		{Offset: headBytes, Bytes: restBytes, Lines: len(reservoir)},
+	}, nil
+}
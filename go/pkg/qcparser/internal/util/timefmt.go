@@ -0,0 +1,30 @@
+package util
+
+import "strings"
+
+// strftimeLayouts maps strftime directives to Go time layout elements
+var strftimeLayouts = strings.NewReplacer(
+	"%Y", "2006",
+	"%y", "06",
+	"%m", "01",
+	"%d", "02",
+	"%-d", "2",
+	"%H", "15",
+	"%I", "03",
+	"%M", "04",
+	"%S", "05",
+	"%f", "000000",
+	"%p", "PM",
+	"%b", "Jan",
+	"%B", "January",
+	"%a", "Mon",
+	"%A", "Monday",
+	"%z", "Z07:00",
+	"%Z", "MST",
+	"%%", "%",
+)
+
+// StrftimeToLayout converts a strftime format (as used by DuckDB) to a Go time layout
+func StrftimeToLayout(format string) string {
+	return strftimeLayouts.Replace(format)
+}
@@ -1,40 +1,66 @@
 package writer
 
 import (
+	"encoding/json"
+	"querycraft/pkg/qcparser/detector"
 	"strconv"
+	"time"
 
 	"github.com/araddon/dateparse"
 )
 
-func convertToDate(value string) string {
+func convertToDate(value string, layout string, locale string) (string, bool) {
+	// Month names are parsed in English
+	value = detector.NormalizeMonths(value, locale)
+
+	// A known layout decides day/month order, like the detected format does
+	// for DuckDB, so values that do not match it are not guessed
+	if layout != "" {
+		parsedTime, err := time.Parse(layout, value)
+		if err != nil {
+			return "", false
+		}
+		return parsedTime.Format("2006-01-02"), true
+	}
+
 	parsedTime, err := dateparse.ParseAny(value)
 	if err != nil {
-		return ""
+		return "", false
 	}
 
-	return parsedTime.Format("2006-01-02")
+	return parsedTime.Format("2006-01-02"), true
 }
 
-func convertToInt(value string) int {
-	intVal, err := strconv.Atoi(value)
-	if err != nil {
-		return 0
+// convertToInt and convertToDouble accept the separators of the column's
+// locale, like inference does
+func convertToInt(value string, locale string) (int, bool) {
+	intVal, ok := detector.ParseInteger(value, locale)
+	if !ok {
+		return 0, false
 	}
-	return intVal
+	return int(intVal), true
 }
 
-func convertToDouble(value string) float64 {
-	floatVal, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		return 0.0
+func convertToDouble(value string, locale string) (float64, bool) {
+	floatVal, ok := detector.ParseNumber(value, locale)
+	if !ok {
+		return 0.0, false
 	}
-	return floatVal
+	return floatVal, true
 }
 
-func convertToBool(value string) bool {
+func convertToBool(value string) (bool, bool) {
 	boolVal, err := strconv.ParseBool(value)
 	if err != nil {
-		return false
+		return false, false
+	}
+	return boolVal, true
+}
+
+func convertToList(value string) ([]string, bool) {
+	var list []string
+	if err := json.Unmarshal([]byte(value), &list); err != nil {
+		return nil, false
 	}
-	return boolVal
+	return list, true
 }
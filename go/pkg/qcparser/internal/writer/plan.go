@@ -0,0 +1,189 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/internal/expr"
+	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
+)
+
+// plan is the per-conversion setup of the writer: the columns to output,
+// the row filter and the date layouts used to parse the source values
+type plan struct {
+	columns       []types.Column // Output columns, in output order
+	ordered       bool           // Keep the output column order instead of sorting keys
+	filter        *expr.Filter
+	filterColumns []types.Column
+	layouts       map[string]string
+	castFailures  map[string]int64 // Values per column that did not parse as the column type
+	partitions    []partitionKey
+}
+
+// partitionKey is a resolved types.PartitionKey
+type partitionKey struct {
+	col    types.Column
+	bucket string
+	name   string // Directory key, the column name with its bucket
+}
+
+// CheckPlan reports an error when opts select, filter or partition by
+// columns config does not have, so it can be checked before rows are read
+func CheckPlan(config *types.DetectResponse, opts *types.Options) error {
+	_, err := newPlan(config, opts)
+	return err
+}
+
+func newPlan(config *types.DetectResponse, opts *types.Options) (*plan, error) {
+	p := &plan{columns: config.Columns, layouts: make(map[string]string), castFailures: make(map[string]int64)}
+
+	// Resolve date layouts once
+	for _, col := range config.Columns {
+		if col.Format != "" {
+			p.layouts[col.Name] = util.StrftimeToLayout(col.Format)
+		}
+	}
+
+	byName := make(map[string]types.Column, len(config.Columns))
+	for _, col := range config.Columns {
+		byName[col.Name] = col
+	}
+
+	if opts != nil && len(opts.Select) > 0 {
+		p.columns = make([]types.Column, 0, len(opts.Select))
+		for _, name := range opts.Select {
+			col, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("selected column %q does not exist", name)
+			}
+			p.columns = append(p.columns, col)
+		}
+		p.ordered = true
+	}
+
+	if opts != nil && opts.Where != "" {
+		filter, err := expr.Compile(opts.Where, config.Columns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid where expression: %w", err)
+		}
+		p.filter = filter
+		for _, name := range filter.Columns() {
+			p.filterColumns = append(p.filterColumns, byName[name])
+		}
+	}
+
+	if opts != nil {
+		for _, key := range opts.PartitionBy {
+			col, ok := byName[key.Column]
+			if !ok {
+				return nil, fmt.Errorf("partition column %q does not exist", key.Column)
+			}
+			if !types.IsPartitionBucket(key.Bucket) {
+				return nil, fmt.Errorf("unknown partition bucket %q", key.Bucket)
+			}
+			name := col.Name
+			if key.Bucket != "" {
+				if col.Type != "DATE" && col.Type != "TIMESTAMP" {
+					return nil, fmt.Errorf("partition column %q is %s, only dates have %s buckets", col.Name, col.Type, key.Bucket)
+				}
+				name += "_" + key.Bucket
+			}
+			p.partitions = append(p.partitions, partitionKey{col: col, bucket: key.Bucket, name: name})
+		}
+	}
+
+	return p, nil
+}
+
+// convert returns the typed value of a column in a row
+func (p *plan) convert(col types.Column, row map[string]string) any {
+	value, ok := row[col.Name]
+	// Missing values (e.g. padded ragged fields) become nulls
+	if !ok {
+		return nil
+	}
+
+	var converted any
+	ok = true
+	switch col.Type {
+	case "TIMESTAMP", "DATE":
+		converted, ok = convertToDate(value, p.layouts[col.Name], col.Locale)
+		if !ok {
+			// Unparseable dates are nulls, an empty string is no TIMESTAMP
+			converted = nil
+		}
+	case "INT":
+		converted, ok = convertToInt(value, col.Locale)
+	case "DOUBLE":
+		converted, ok = convertToDouble(value, col.Locale)
+	case "BOOLEAN":
+		converted, ok = convertToBool(value)
+	case "TEXT[]":
+		converted, ok = convertToList(value)
+	default:
+		converted = value
+	}
+
+	// Null cells are expected to fail, only count real values
+	if !ok && !detector.IsNullValue(value) {
+		p.castFailures[col.Name]++
+	}
+	return converted
+}
+
+// typedValue returns the filter's view of a row: converted values, with
+// missing, empty, null-token and unparseable date cells as nulls
+func (p *plan) typedValue(row map[string]string, converted map[string]any) func(string) any {
+	return func(name string) any {
+		raw, ok := row[name]
+		if !ok || detector.IsNullValue(raw) {
+			return nil
+		}
+		value := converted[name]
+		if s, isString := value.(string); isString && s == "" {
+			return nil
+		}
+		return value
+	}
+}
+
+// project returns the output columns of a converted row in select order
+func (p *plan) project(converted map[string]any) orderedRow {
+	row := orderedRow{keys: make([]string, len(p.columns)), values: make([]any, len(p.columns))}
+	for i, col := range p.columns {
+		row.keys[i] = col.Name
+		row.values[i] = converted[col.Name]
+	}
+	return row
+}
+
+// orderedRow is a JSON object whose keys keep their order
+type orderedRow struct {
+	keys   []string
+	values []any
+}
+
+func (r orderedRow) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
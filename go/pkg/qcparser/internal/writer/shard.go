@@ -0,0 +1,260 @@
+package writer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"querycraft/pkg/qcparser/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxOpenShards bounds the files kept open while partitioning
+	maxOpenShards = 64
+
+	// defaultPartition names the directory of null partition values, as Hive does
+	defaultPartition = "__HIVE_DEFAULT_PARTITION__"
+)
+
+// Sharded reports whether opts split the output into several files, in
+// which case the output path is a directory
+func Sharded(opts *types.Options) bool {
+	return opts != nil && (opts.MaxRowsPerFile > 0 || opts.MaxBytesPerFile > 0 || len(opts.PartitionBy) > 0)
+}
+
+// sink receives the encoded rows, with the partition directory of each
+type sink interface {
+	write(partition string, line []byte) error
+}
+
+// streamSink writes every row to one writer
+type streamSink struct {
+	w *countingWriter
+}
+
+func (s *streamSink) write(_ string, line []byte) error {
+	_, err := s.w.Write(line)
+	return err
+}
+
+// shardSink writes rows to part files under a directory per partition,
+// starting a new file when one reaches the row or byte limit
+type shardSink struct {
+	dir        string
+	maxRows    int64
+	maxBytes   int64
+	partitions map[string]*partitionFiles
+	shards     []*shard // Every file, in creation order
+	open       []*shard // Files with an open handle, oldest first
+}
+
+// partitionFiles is the current part file of a partition directory
+type partitionFiles struct {
+	dir     string
+	next    int
+	current *shard
+}
+
+// shard is a part file
+type shard struct {
+	path  string
+	rows  int64
+	bytes int64
+	file  *os.File
+	buf   *bufio.Writer
+}
+
+func newShardSink(dir string, opts *types.Options) *shardSink {
+	return &shardSink{
+		dir:        dir,
+		maxRows:    opts.MaxRowsPerFile,
+		maxBytes:   opts.MaxBytesPerFile,
+		partitions: make(map[string]*partitionFiles),
+	}
+}
+
+func (s *shardSink) write(partition string, line []byte) error {
+	p, ok := s.partitions[partition]
+	if !ok {
+		p = &partitionFiles{dir: filepath.Join(s.dir, partition)}
+		if err := os.MkdirAll(p.dir, 0755); err != nil {
+			return err
+		}
+		s.partitions[partition] = p
+	}
+
+	// Roll over to a new part file, never leaving one empty
+	current := p.current
+	if current == nil ||
+		(s.maxRows > 0 && current.rows >= s.maxRows) ||
+		(s.maxBytes > 0 && current.rows > 0 && current.bytes+int64(len(line)) > s.maxBytes) {
+		if current != nil {
+			if err := s.release(current); err != nil {
+				return err
+			}
+		}
+		current = &shard{path: filepath.Join(p.dir, fmt.Sprintf("part-%05d.djson", p.next))}
+		p.next++
+		p.current = current
+		s.shards = append(s.shards, current)
+	}
+
+	if current.file == nil {
+		if err := s.reopen(current); err != nil {
+			return err
+		}
+	}
+	if _, err := current.buf.Write(line); err != nil {
+		return err
+	}
+	current.rows++
+	current.bytes += int64(len(line))
+	return nil
+}
+
+// reopen opens a part file, closing the oldest open one past the limit
+func (s *shardSink) reopen(sh *shard) error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if sh.bytes == 0 {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(sh.path, flags, 0644)
+	if err != nil {
+		return err
+	}
+	sh.file, sh.buf = file, bufio.NewWriterSize(file, 64<<10)
+	s.open = append(s.open, sh)
+
+	if len(s.open) > maxOpenShards {
+		return s.release(s.open[0])
+	}
+	return nil
+}
+
+// release flushes and closes a part file, which may be reopened later
+func (s *shardSink) release(sh *shard) error {
+	for i, open := range s.open {
+		if open == sh {
+			s.open = append(s.open[:i], s.open[i+1:]...)
+			break
+		}
+	}
+	if sh.file == nil {
+		return nil
+	}
+
+	err := sh.buf.Flush()
+	if closeErr := sh.file.Close(); err == nil {
+		err = closeErr
+	}
+	sh.file, sh.buf = nil, nil
+	return err
+}
+
+func (s *shardSink) close() error {
+	var err error
+	for len(s.open) > 0 {
+		if releaseErr := s.release(s.open[0]); err == nil {
+			err = releaseErr
+		}
+	}
+	return err
+}
+
+// files lists the part files written
+func (s *shardSink) files() []types.OutputFile {
+	files := make([]types.OutputFile, len(s.shards))
+	for i, sh := range s.shards {
+		files[i] = types.OutputFile{Path: sh.path, Rows: sh.rows, Bytes: sh.bytes}
+	}
+	return files
+}
+
+// writeShards writes the rows as part files under dir
+func writeShards(rowChan <-chan map[string]string, config *types.DetectResponse, opts *types.Options, dir string) (*types.ConvertResult, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	shards := newShardSink(dir, opts)
+	result, err := writeRows(rowChan, config, opts, shards)
+	if closeErr := shards.close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Files = shards.files()
+	for _, file := range result.Files {
+		result.BytesWritten += file.Bytes
+	}
+	return result, nil
+}
+
+// partition returns the Hive-style directory of a converted row, such as
+// "country=FR/ts_month=2024-01"
+func (p *plan) partition(converted map[string]any) string {
+	if len(p.partitions) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(p.partitions))
+	for i, key := range p.partitions {
+		parts[i] = escapePartition(key.name) + "=" + escapePartition(partitionValue(converted[key.col.Name], key.bucket))
+	}
+	return filepath.Join(parts...)
+}
+
+// partitionValue formats a typed value as a partition directory value
+func partitionValue(value any, bucket string) string {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return defaultPartition
+	case string:
+		s = v
+	case int:
+		s = strconv.Itoa(v)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		s = strconv.FormatBool(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" {
+		return defaultPartition
+	}
+
+	// Dates are converted to YYYY-MM-DD
+	switch bucket {
+	case types.BucketYear:
+		s = s[:min(len(s), 4)]
+	case types.BucketMonth:
+		s = s[:min(len(s), 7)]
+	case types.BucketDay:
+		s = s[:min(len(s), 10)]
+	}
+	return s
+}
+
+// escapePartition percent-encodes the characters that are unsafe in a
+// directory name, like Hive's partition path escaping
+func escapePartition(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == 0x7f || strings.IndexByte("\"#%'*/:=?\\{[]^ ", c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	if b.Len() == 0 || b.String() == "." || b.String() == ".." {
+		return defaultPartition
+	}
+	return b.String()
+}
@@ -3,14 +3,35 @@ package writer
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"querycraft/pkg/qcparser/internal/util"
 	"querycraft/pkg/qcparser/types"
 	"time"
 )
 
-func Write(rowChan <-chan map[string]string, config *types.DetectResponse, outPath string) (*types.ConvertResult, error) {
-	start := time.Now()
+// Write converts rows to DJSON and writes them to the file at outPath. When
+// opts shard or partition the output, outPath is a directory receiving the
+// part files. The caller writes the manifest once the result is final.
+func Write(rowChan <-chan map[string]string, config *types.DetectResponse, opts *types.Options, outPath string) (*types.ConvertResult, error) {
+	var result *types.ConvertResult
+	var err error
+	if Sharded(opts) {
+		result, err = writeShards(rowChan, config, opts, outPath)
+	} else {
+		result, err = writeFile(rowChan, config, opts, outPath)
+	}
+	if err != nil {
+		drain(rowChan)
+		return nil, err
+	}
+	result.DJSONPath = outPath
+	return result, nil
+}
 
+// writeFile writes the rows to a single DJSON file
+func writeFile(rowChan <-chan map[string]string, config *types.DetectResponse, opts *types.Options, outPath string) (*types.ConvertResult, error) {
 	// Create or truncate the DJSON file
 	file, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
@@ -18,46 +39,172 @@ func Write(rowChan <-chan map[string]string, config *types.DetectResponse, outPa
 	}
 	defer file.Close()
 
-	var rowsWritten int64
-	encoder := json.NewEncoder(file)
+	result, err := WriteTo(rowChan, config, opts, file)
+	if err != nil {
+		return nil, err
+	}
+	result.Files = []types.OutputFile{{Path: outPath, Rows: result.RowsWritten, Bytes: result.BytesWritten}}
+	return result, nil
+}
+
+// Source is an input of a conversion as recorded in the manifest
+type Source struct {
+	Path string
+	Info os.FileInfo
+	Hash *util.HashReader // Reads the input, so its digest is complete once every row was written
+}
+
+// NewSource wraps an opened input so that it is hashed while read
+func NewSource(path string, file *os.File) (*Source, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &Source{Path: path, Info: info, Hash: util.NewHashReader(file)}, nil
+}
+
+// WriteManifest writes the manifest of the DJSON output at outPath
+func WriteManifest(outPath string, config *types.DetectResponse, opts *types.Options, sources []*Source, result *types.ConvertResult, started time.Time) error {
+	manifest := types.Manifest{
+		ToolVersion:  types.ToolVersion,
+		Sources:      make([]types.SourceInfo, 0, len(sources)),
+		Detection:    config,
+		RowsWritten:  result.RowsWritten,
+		RowsFiltered: result.RowsFiltered,
+		BytesWritten: result.BytesWritten,
+		CastFailures: result.CastFailures,
+		Files:        result.Files,
+		StartedAt:    started.UTC(),
+		FinishedAt:   time.Now().UTC(),
+
+		DuplicatesRemoved: result.DuplicatesRemoved,
+		RaggedRows:        result.RaggedRows,
+		Errors:            result.Errors,
+	}
+	if opts != nil {
+		manifest.Options = *opts
+	}
+	if manifest.CastFailures == nil {
+		manifest.CastFailures = map[string]int64{}
+	}
+
+	for _, source := range sources {
+		path, err := filepath.Abs(source.Path)
+		if err != nil {
+			path = source.Path
+		}
+		manifest.Sources = append(manifest.Sources, types.SourceInfo{
+			Path:    path,
+			Size:    source.Info.Size(),
+			SHA256:  source.Hash.Sum(),
+			ModTime: source.Info.ModTime().UTC(),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(types.ManifestPath(outPath), data, 0644)
+}
+
+// WriteTo converts rows to DJSON and writes them to w, keeping the rows
+// matching opts.Where and the columns listed in opts.Select
+func WriteTo(rowChan <-chan map[string]string, config *types.DetectResponse, opts *types.Options, w io.Writer) (*types.ConvertResult, error) {
+	counter := &countingWriter{w: w}
+	result, err := writeRows(rowChan, config, opts, &streamSink{w: counter})
+	if err != nil {
+		drain(rowChan)
+		return nil, err
+	}
+	result.BytesWritten = counter.n
+	return result, nil
+}
+
+// writeRows converts rows and passes each encoded row to out
+func writeRows(rowChan <-chan map[string]string, config *types.DetectResponse, opts *types.Options, out sink) (*types.ConvertResult, error) {
+	start := time.Now()
+
+	plan, err := newPlan(config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rowsWritten, rowsFiltered int64
 
 	// Process ALL rows from channel
 	for row := range rowChan {
+		// Convert the columns read by the filter first, skip the row if it fails
+		convertedRow := make(map[string]any, len(plan.columns))
+		if plan.filter != nil {
+			for _, col := range plan.filterColumns {
+				convertedRow[col.Name] = plan.convert(col, row)
+			}
+			if !plan.filter.Match(plan.typedValue(row, convertedRow)) {
+				rowsFiltered++
+				continue
+			}
+		}
+
 		// Create converted row maintaining column order
-		convertedRow := make(map[string]any)
-		for _, col := range config.Columns {
-			switch col.Type {
-			case "TIMESTAMP":
-				convertedRow[col.Name] = convertToDate(row[col.Name])
-			case "INT":
-				convertedRow[col.Name] = convertToInt(row[col.Name])
-			case "DOUBLE":
-				convertedRow[col.Name] = convertToDouble(row[col.Name])
-			case "BOOLEAN":
-				convertedRow[col.Name] = convertToBool(row[col.Name])
-			default:
-				convertedRow[col.Name] = row[col.Name]
+		for _, col := range plan.columns {
+			if _, done := convertedRow[col.Name]; !done {
+				convertedRow[col.Name] = plan.convert(col, row)
 			}
 		}
 
-		// Write as JSON line (encoder adds newline automatically)
-		if err := encoder.Encode(convertedRow); err != nil {
+		// Partition columns may be left out of the selected ones
+		for _, key := range plan.partitions {
+			if _, done := convertedRow[key.col.Name]; !done {
+				convertedRow[key.col.Name] = plan.convert(key.col, row)
+			}
+		}
+
+		var encoded any = convertedRow
+		if plan.ordered {
+			encoded = plan.project(convertedRow)
+		}
+
+		// Write as JSON line
+		line, err := json.Marshal(encoded)
+		if err != nil {
 			return nil, fmt.Errorf("error encoding row %d: %w", rowsWritten+1, err)
 		}
+		if err := out.write(plan.partition(convertedRow), append(line, '\n')); err != nil {
+			return nil, err
+		}
 
 		rowsWritten++
 	}
 
-	// Get file size
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return nil, err
+	var castFailures map[string]int64
+	if len(plan.castFailures) > 0 {
+		castFailures = plan.castFailures
 	}
 
 	return &types.ConvertResult{
-		DJSONPath:    outPath,
 		RowsWritten:  rowsWritten,
-		BytesWritten: fileInfo.Size(),
+		RowsFiltered: rowsFiltered,
 		DurationMs:   time.Since(start).Milliseconds(),
+		CastFailures: castFailures,
 	}, nil
 }
+
+// drain consumes the rows left after a failed write, so the goroutines
+// feeding rowChan do not block forever holding the input open
+func drain(rowChan <-chan map[string]string) {
+	for range rowChan {
+	}
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,30 @@
+package qcparser
+
+import (
+	"querycraft/pkg/qcparser/internal/mask"
+	"querycraft/pkg/qcparser/types"
+)
+
+// CheckMasks reports errors in mask rules before any input is read; column
+// names are checked once they are known
+func CheckMasks(rules []types.MaskRule, salt string) error {
+	return mask.Check(rules, salt)
+}
+
+// compileMasks resolves opts.Masks against the columns of the rows and
+// returns the columns of the masked rows
+func compileMasks(config *types.DetectResponse, opts *types.Options) (*mask.Masker, *types.DetectResponse, error) {
+	masker, err := mask.New(config, opts.Masks, opts.MaskSalt)
+	if err != nil || masker == nil {
+		return nil, config, err
+	}
+	return masker, masker.Config(), nil
+}
+
+// maskRows masks the rows when there is a masker
+func maskRows(rows <-chan map[string]string, masker *mask.Masker) <-chan map[string]string {
+	if masker == nil {
+		return rows
+	}
+	return mask.Rows(rows, masker)
+}
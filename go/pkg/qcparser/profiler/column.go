@@ -0,0 +1,142 @@
+package profiler
+
+import (
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/internal/sketch"
+	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
+	"time"
+	"unicode/utf8"
+
+	"github.com/araddon/dateparse"
+)
+
+// columnProfiler accumulates statistics for one column
+type columnProfiler struct {
+	column    types.Column
+	layout    string // Known date layout, dateparse is only used without one
+	count     int64
+	nulls     int64
+	invalid   int64
+	distinct  *sketch.HyperLogLog
+	top       *sketch.TopK
+	numbers   sketch.Moments
+	histogram *sketch.Histogram
+	minLength int
+	maxLength int
+	minTime   time.Time
+	maxTime   time.Time
+}
+
+func newColumnProfiler(column types.Column, topK int) *columnProfiler {
+	layout := ""
+	if column.Format != "" {
+		layout = util.StrftimeToLayout(column.Format)
+	}
+	return &columnProfiler{
+		column:    column,
+		layout:    layout,
+		distinct:  sketch.NewHyperLogLog(),
+		top:       sketch.NewTopK(topK),
+		histogram: sketch.NewHistogram(),
+	}
+}
+
+// add records a cell value; present is false for missing (padded) fields
+func (c *columnProfiler) add(value string, present bool) {
+	if !present || detector.IsNullValue(value) {
+		c.nulls++
+		return
+	}
+
+	c.count++
+	c.distinct.Add(value)
+	c.top.Add(value)
+
+	switch c.column.Type {
+	case "INT", "DOUBLE":
+		number, ok := detector.ParseNumber(value, c.column.Locale)
+		if !ok {
+			c.invalid++
+			return
+		}
+		c.numbers.Add(number)
+		c.histogram.Add(number)
+	case "TIMESTAMP":
+		parsed, ok := c.parseTime(value)
+		if !ok {
+			c.invalid++
+			return
+		}
+		if c.minTime.IsZero() || parsed.Before(c.minTime) {
+			c.minTime = parsed
+		}
+		if c.maxTime.IsZero() || parsed.After(c.maxTime) {
+			c.maxTime = parsed
+		}
+	case "TEXT":
+		length := utf8.RuneCountInString(value)
+		if c.count == 1 || length < c.minLength {
+			c.minLength = length
+		}
+		if length > c.maxLength {
+			c.maxLength = length
+		}
+	}
+}
+
+// result builds the column profile
+func (c *columnProfiler) result(bins int) types.ColumnProfile {
+	profile := types.ColumnProfile{
+		Name:           c.column.Name,
+		Type:           c.column.Type,
+		Count:          c.count,
+		NullCount:      c.nulls,
+		InvalidCount:   c.invalid,
+		DistinctApprox: c.distinct.Count(),
+		TopValues:      make([]types.ValueCount, 0),
+	}
+
+	for _, v := range c.top.Values() {
+		profile.TopValues = append(profile.TopValues, types.ValueCount{Value: v.Value, Count: v.Count})
+	}
+
+	switch c.column.Type {
+	case "INT", "DOUBLE":
+		if c.numbers.Count > 0 {
+			mean := c.numbers.Mean()
+			stddev := c.numbers.StdDev()
+			profile.Min = c.numbers.Min
+			profile.Max = c.numbers.Max
+			profile.Mean = &mean
+			profile.StdDev = &stddev
+			for _, b := range c.histogram.Bins(bins, c.numbers.Min, c.numbers.Max) {
+				profile.Histogram = append(profile.Histogram, types.HistogramBin{Low: b.Low, High: b.High, Count: b.Count})
+			}
+		}
+	case "TIMESTAMP":
+		if !c.minTime.IsZero() {
+			profile.Min = c.minTime.Format(time.RFC3339)
+			profile.Max = c.maxTime.Format(time.RFC3339)
+		}
+	case "TEXT":
+		if c.count > 0 {
+			minLength, maxLength := c.minLength, c.maxLength
+			profile.MinLength = &minLength
+			profile.MaxLength = &maxLength
+		}
+	}
+
+	return profile
+}
+
+// parseTime parses a TIMESTAMP cell with the column's layout, like the writer
+func (c *columnProfiler) parseTime(value string) (time.Time, bool) {
+	value = detector.NormalizeMonths(value, c.column.Locale)
+	if c.layout != "" {
+		t, err := time.Parse(c.layout, value)
+		return t, err == nil
+	}
+	t, err := dateparse.ParseAny(value)
+	return t, err == nil
+}
@@ -0,0 +1,105 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/internal/reader"
+	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
+	"time"
+)
+
+// Profile streams a whole file and computes statistics for every column
+func Profile(filePath string, opts *types.Options) (*types.ProfileResponse, error) {
+	return ProfileContext(context.Background(), filePath, opts, nil)
+}
+
+// ProfileContext is like Profile but stops when ctx is cancelled and
+// reports progress to the optional progress func
+func ProfileContext(ctx context.Context, filePath string, opts *types.Options, progress types.ProgressFunc) (*types.ProfileResponse, error) {
+	start := time.Now()
+
+	detected, err := detector.Detect(filePath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("detection failed: %w", err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+
+	input := util.NewProgressReader(ctx, file)
+	rowChan, errChan, _ := reader.ReadFrom(input, detected, opts)
+	rows, stop := util.TrackRows(rowChan, input, size, progress)
+	result := profileRows(rows, errChan, detected, opts, start)
+	stop()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return result, nil
+}
+
+// ProfileReader is like Profile but reads the input from a stream
+func ProfileReader(r io.Reader, opts *types.Options) (*types.ProfileResponse, error) {
+	start := time.Now()
+
+	detected, replay, err := detector.DetectReader(r, opts)
+	if err != nil {
+		return nil, fmt.Errorf("detection failed: %w", err)
+	}
+
+	rowChan, errChan, _ := reader.ReadFrom(replay, detected, opts)
+	return profileRows(rowChan, errChan, detected, opts, start), nil
+}
+
+// profileRows consumes the row and error channels and builds the response
+func profileRows(rowChan <-chan map[string]string, errChan <-chan error, detected *types.DetectResponse, opts *types.Options, start time.Time) *types.ProfileResponse {
+	// Count rejected rows in the background
+	var invalidRows int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range errChan {
+			invalidRows++
+		}
+	}()
+
+	columns := make([]*columnProfiler, len(detected.Columns))
+	for i, col := range detected.Columns {
+		columns[i] = newColumnProfiler(col, opts.TopK)
+	}
+
+	var rows int64
+	for row := range rowChan {
+		rows++
+		for _, col := range columns {
+			value, ok := row[col.column.Name]
+			col.add(value, ok)
+		}
+	}
+	<-done
+
+	profiles := make([]types.ColumnProfile, len(columns))
+	for i, col := range columns {
+		profiles[i] = col.result(opts.HistogramBins)
+	}
+
+	return &types.ProfileResponse{
+		Format:      detected.Format,
+		Rows:        rows,
+		InvalidRows: invalidRows,
+		Columns:     profiles,
+		DurationMs:  util.DurationMs(start),
+	}
+}
@@ -0,0 +1,170 @@
+package qcparser
+
+import (
+	"fmt"
+	"querycraft/pkg/qcparser/types"
+	"strings"
+)
+
+// minColumnOverlap is the share of the narrower of an input and the first
+// input whose column names must match for the input to join the dataset
+const minColumnOverlap = 0.5
+
+// dataset accumulates the reconciled schema of several detected inputs
+type dataset struct {
+	first   *types.DetectResponse
+	columns []types.Column
+	index   map[string]int // Lowercased column name to position in columns
+	issues  []types.Issue
+}
+
+func newDataset() *dataset {
+	return &dataset{index: make(map[string]int)}
+}
+
+// add merges a detected input into the dataset schema, or explains why it does not fit
+func (d *dataset) add(path string, detected *types.DetectResponse) error {
+	if d.first == nil {
+		d.first = detected
+		for _, col := range detected.Columns {
+			d.index[columnKey(col.Name)] = len(d.columns)
+			d.columns = append(d.columns, col)
+		}
+		return nil
+	}
+
+	if err := d.compatible(detected); err != nil {
+		return err
+	}
+
+	var unmatched []string
+	for _, col := range detected.Columns {
+		i, ok := d.index[columnKey(col.Name)]
+		if !ok {
+			// Columns missing from earlier inputs are null there
+			d.index[columnKey(col.Name)] = len(d.columns)
+			d.columns = append(d.columns, types.Column{Name: col.Name, Type: col.Type, Format: col.Format})
+			unmatched = append(unmatched, col.Name)
+			continue
+		}
+
+		current := d.columns[i]
+		widened := widenType(current.Type, col.Type)
+		if widened != current.Type {
+			d.issues = append(d.issues, types.Issue{
+				Code:    "TYPE_WIDENED",
+				Message: fmt.Sprintf("Column %q widened from %s to %s for %s", current.Name, current.Type, widened, path),
+			})
+			d.columns[i].Type = widened
+		}
+	}
+	if len(unmatched) > 0 && detected.HasHeader {
+		d.issues = append(d.issues, types.Issue{
+			Code:    "COLUMNS_UNMATCHED",
+			Message: fmt.Sprintf("Columns %s of %s match no column of the earlier inputs", strings.Join(unmatched, ", "), path),
+		})
+	}
+	return nil
+}
+
+// compatible checks that an input can be matched against the dataset columns
+func (d *dataset) compatible(detected *types.DetectResponse) error {
+	if detected.Format != d.first.Format {
+		return fmt.Errorf("format %s differs from %s", detected.Format, d.first.Format)
+	}
+	if detected.HasHeader != d.first.HasHeader {
+		return fmt.Errorf("header row presence differs from the first input")
+	}
+
+	// Without headers columns are matched by position, so the width must agree
+	if !detected.HasHeader {
+		if detected.FieldCount != d.first.FieldCount {
+			return fmt.Errorf("has %d columns without a header, expected %d", detected.FieldCount, d.first.FieldCount)
+		}
+		return nil
+	}
+
+	shared := 0
+	for _, col := range detected.Columns {
+		if _, ok := d.index[columnKey(col.Name)]; ok {
+			shared++
+		}
+	}
+	narrower := min(len(detected.Columns), len(d.first.Columns))
+	if float64(shared) < minColumnOverlap*float64(narrower) || shared == 0 {
+		return fmt.Errorf("shares only %d of %d column names with the dataset", shared, len(detected.Columns))
+	}
+	return nil
+}
+
+// inputConfig returns the writer config of one input: the dataset columns,
+// keeping the input's own source formats and locales for parsing
+func (d *dataset) inputConfig(detected *types.DetectResponse, sourceColumn string) *types.DetectResponse {
+	config := *detected
+	config.Columns = d.schema("")
+
+	for _, col := range detected.Columns {
+		i := d.index[columnKey(col.Name)]
+		if col.Type == config.Columns[i].Type {
+			config.Columns[i].Format = col.Format
+		}
+		config.Columns[i].Locale = col.Locale
+	}
+
+	if sourceColumn != "" {
+		config.Columns = append(config.Columns, types.Column{Name: sourceColumn, Type: "TEXT"})
+	}
+	return &config
+}
+
+// renames maps an input's column names to the dataset names they matched
+func (d *dataset) renames(detected *types.DetectResponse) map[string]string {
+	renames := make(map[string]string)
+	for _, col := range detected.Columns {
+		name := d.columns[d.index[columnKey(col.Name)]].Name
+		if name != col.Name {
+			renames[col.Name] = name
+		}
+	}
+	return renames
+}
+
+// schema returns the reconciled columns
+func (d *dataset) schema(sourceColumn string) []types.Column {
+	columns := append([]types.Column(nil), d.columns...)
+	for i := range columns {
+		columns[i].Format = ""
+		columns[i].Locale = ""
+	}
+	if sourceColumn != "" {
+		columns = append(columns, types.Column{Name: sourceColumn, Type: "TEXT"})
+	}
+	return columns
+}
+
+// widenType returns the narrowest type holding values of both a and b
+func widenType(a, b string) string {
+	switch {
+	case a == b:
+		return a
+	case isNumeric(a) && isNumeric(b):
+		return "DOUBLE"
+	case isTemporal(a) && isTemporal(b):
+		return "TIMESTAMP"
+	default:
+		return "TEXT"
+	}
+}
+
+func isNumeric(t string) bool {
+	return t == "INT" || t == "DOUBLE"
+}
+
+func isTemporal(t string) bool {
+	return t == "DATE" || t == "TIMESTAMP"
+}
+
+// columnKey matches column names across inputs regardless of case and padding
+func columnKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
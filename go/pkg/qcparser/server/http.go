@@ -0,0 +1,442 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"querycraft/pkg/qcparser"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
+	"strconv"
+	"time"
+)
+
+// HTTPConfig contains the limits of an HTTP server
+type HTTPConfig struct {
+	MaxUploadBytes int64         // Largest accepted request body
+	MaxConcurrent  int           // Requests and asynchronous jobs running at once
+	TempDir        string        // Where asynchronous jobs keep uploads and outputs; "" for the system default
+	JobTTL         time.Duration // How long a finished job and its output are kept; 0 keeps them until deleted
+}
+
+// DefaultHTTPConfig returns the default HTTP server limits
+func DefaultHTTPConfig() HTTPConfig {
+	return HTTPConfig{
+		MaxUploadBytes: 4 << 30, // 4GB
+		MaxConcurrent:  4,
+		JobTTL:         time.Hour,
+	}
+}
+
+// HTTPServer serves detection and conversion over HTTP:
+//
+//	POST   /detect            detect an uploaded file
+//	POST   /preview           columns and preview rows of an uploaded file
+//	POST   /convert           stream the upload back as DJSON, or start a job with ?async=1
+//	GET    /jobs/{id}         job status, as Server-Sent Events with Accept: text/event-stream
+//	GET    /jobs/{id}/output  DJSON produced by a finished job
+//	DELETE /jobs/{id}         cancel a job and delete its files
+//
+// Uploads are the raw request body or the "file" part of a multipart form.
+// Options overrides are passed as JSON in the "options" query parameter.
+// Finished jobs are deleted with their files once HTTPConfig.JobTTL has passed.
+type HTTPServer struct {
+	config HTTPConfig
+	mux    *http.ServeMux
+	slots  chan struct{}
+	jobs   *jobStore
+}
+
+// NewHTTPServer returns an HTTP server with the given limits
+func NewHTTPServer(config HTTPConfig) *HTTPServer {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 1
+	}
+
+	s := &HTTPServer{
+		config: config,
+		mux:    http.NewServeMux(),
+		slots:  make(chan struct{}, config.MaxConcurrent),
+		jobs:   newJobStore(config.JobTTL),
+	}
+
+	s.mux.HandleFunc("POST /detect", s.limited(s.handleDetect))
+	s.mux.HandleFunc("POST /preview", s.limited(s.handleDetect))
+	s.mux.HandleFunc("POST /convert", s.limited(s.handleConvert))
+	s.mux.HandleFunc("GET /jobs/{id}", s.handleJob)
+	s.mux.HandleFunc("GET /jobs/{id}/output", s.handleJobOutput)
+	s.mux.HandleFunc("DELETE /jobs/{id}", s.handleJobDelete)
+
+	return s
+}
+
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close cancels the running jobs and deletes the files of every job
+func (s *HTTPServer) Close() {
+	s.jobs.removeAll()
+}
+
+// slot is the concurrency slot held by a request. An asynchronous job takes
+// it over, so that it is released when the job ends instead of the request
+type slot struct {
+	taken bool
+}
+
+// limitedHandler handles a request holding a concurrency slot
+type limitedHandler func(w http.ResponseWriter, r *http.Request, held *slot)
+
+// limited rejects requests over the concurrency limit and caps the body size
+func (s *HTTPServer) limited(handler limitedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.acquire() {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusTooManyRequests, "BUSY", "Too many concurrent requests")
+			return
+		}
+		held := &slot{}
+		defer func() {
+			if !held.taken {
+				s.release()
+			}
+		}()
+
+		if s.config.MaxUploadBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxUploadBytes)
+		}
+		handler(w, r, held)
+	}
+}
+
+func (s *HTTPServer) acquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *HTTPServer) release() {
+	<-s.slots
+}
+
+// handleDetect detects an upload and returns the full response or only the preview
+func (s *HTTPServer) handleDetect(w http.ResponseWriter, r *http.Request, _ *slot) {
+	opts, body, ok := s.request(w, r)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	detected, _, err := detector.DetectReader(util.NewProgressReader(r.Context(), body), opts)
+	if err != nil {
+		writeJobError(w, failed("DETECTION_FAILED", err))
+		return
+	}
+
+	if r.URL.Path == "/preview" {
+		writeJSON(w, http.StatusOK, &PreviewResult{Columns: detected.Columns, Preview: detected.Preview})
+		return
+	}
+	writeJSON(w, http.StatusOK, detected)
+}
+
+// handleConvert streams the converted upload back, or starts an asynchronous job
+func (s *HTTPServer) handleConvert(w http.ResponseWriter, r *http.Request, held *slot) {
+	opts, body, ok := s.request(w, r)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		s.startJob(w, body, opts, held)
+		return
+	}
+
+	// Errors after the first row can only be reported in trailers
+	w.Header().Set("Trailer", "X-Rows-Written, X-Error")
+	out := &lazyHeaderWriter{w: w, contentType: "application/x-ndjson"}
+	buffered := bufio.NewWriter(out)
+
+	input := util.NewProgressReader(r.Context(), body)
+	result, err := qcparser.ConvertStream(input, buffered, opts)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err == nil {
+		// The reader reports read failures as row errors; the output is incomplete
+		err = input.Err()
+	}
+	if err != nil {
+		if !out.started {
+			writeJobError(w, failed("CONVERSION_FAILED", err))
+			return
+		}
+		w.Header().Set("X-Error", err.Error())
+		return
+	}
+
+	out.start()
+	w.Header().Set("X-Rows-Written", strconv.FormatInt(result.RowsWritten, 10))
+}
+
+// startJob spools the upload to disk and converts it in the background
+func (s *HTTPServer) startJob(w http.ResponseWriter, body io.Reader, opts *types.Options, held *slot) {
+	input, err := s.spool(body)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	output := input + ".djson"
+
+	// The job outlives the request and keeps its concurrency slot until it ends
+	held.taken = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := s.jobs.add(cancel, input, output)
+
+	go func() {
+		defer s.release()
+		defer os.Remove(input)
+
+		progress := func(p types.Progress) {
+			job.update(func(s *JobStatus) { s.Progress = p })
+		}
+		result, err := qcparser.ConvertContext(ctx, input, output, opts, progress)
+		if result != nil {
+			result.DJSONPath = ""
+		}
+		job.finish(result, failed("CONVERSION_FAILED", err))
+	}()
+
+	status, _ := job.snapshot()
+	w.Header().Set("Location", "/jobs/"+status.ID)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+// spool copies an upload into a temporary file
+func (s *HTTPServer) spool(body io.Reader) (string, error) {
+	file, err := os.CreateTemp(s.config.TempDir, "qcparser-upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, body); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// handleJob returns a job's status, or streams it as Server-Sent Events
+func (s *HTTPServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "JOB_NOT_FOUND", "No job with this id")
+		return
+	}
+
+	if accepts, _, _ := mime.ParseMediaType(r.Header.Get("Accept")); accepts != "text/event-stream" {
+		status, _ := job.snapshot()
+		writeJSON(w, http.StatusOK, status)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "STREAMING_UNSUPPORTED", "Streaming is not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// One progress event per update, then a final event named after the end state
+	for {
+		status, changed := job.snapshot()
+		event := "progress"
+		if status.State != JobRunning {
+			event = status.State
+		}
+		writeEvent(w, event, status)
+		flusher.Flush()
+		if status.State != JobRunning {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleJobOutput streams the DJSON of a finished job
+func (s *HTTPServer) handleJobOutput(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "JOB_NOT_FOUND", "No job with this id")
+		return
+	}
+
+	status, _ := job.snapshot()
+	if status.State != JobDone {
+		writeError(w, http.StatusConflict, "JOB_NOT_DONE", fmt.Sprintf("Job is %s", status.State))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	http.ServeFile(w, r, job.output)
+}
+
+// handleJobDelete cancels a job and deletes its files
+func (s *HTTPServer) handleJobDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.jobs.remove(r.PathValue("id")) {
+		writeError(w, http.StatusNotFound, "JOB_NOT_FOUND", "No job with this id")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// request decodes the options and opens the upload of a request
+func (s *HTTPServer) request(w http.ResponseWriter, r *http.Request) (*types.Options, io.ReadCloser, bool) {
+	opts, err := decodeOptions([]byte(r.URL.Query().Get("options")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_OPTIONS", err.Error())
+		return nil, nil, false
+	}
+
+	body, err := upload(r)
+	if err != nil {
+		writeUploadError(w, err)
+		return nil, nil, false
+	}
+	return opts, body, true
+}
+
+// upload returns the "file" part of a multipart request, or the raw body
+func upload(r *http.Request) (io.ReadCloser, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	parts, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("multipart form has no file part")
+			}
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// lazyHeaderWriter sets the status and content type on the first write, so
+// errors before any output can still be reported with an error status
+type lazyHeaderWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+func (l *lazyHeaderWriter) start() {
+	if !l.started {
+		l.started = true
+		l.w.Header().Set("Content-Type", l.contentType)
+		l.w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (l *lazyHeaderWriter) Write(p []byte) (int, error) {
+	l.start()
+	return l.w.Write(p)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeEvent(w io.Writer, event string, v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// writeError writes an error body shaped like the CLI's stderr errors
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	var resp types.ErrorResponse
+	resp.Error.Code = code
+	resp.Error.Message = message
+	writeJSON(w, status, resp)
+}
+
+// writeJobError maps a failed job to an error response
+func writeJobError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeUploadError(w, tooLarge)
+	case errors.Is(err, detector.ErrSchemaMismatch):
+		writeError(w, http.StatusUnprocessableEntity, errorCode(err), err.Error())
+	case errors.Is(err, context.Canceled):
+		writeError(w, http.StatusRequestTimeout, "CANCELLED", err.Error())
+	default:
+		writeError(w, http.StatusUnprocessableEntity, errorCode(err), err.Error())
+	}
+}
+
+// writeUploadError reports an upload that could not be read
+func writeUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "UPLOAD_TOO_LARGE", fmt.Sprintf("Upload exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	writeError(w, http.StatusBadRequest, "INVALID_UPLOAD", err.Error())
+}
+
+// ListenAndServe serves the handler on addr until ctx is cancelled
+func ListenAndServe(ctx context.Context, addr string, handler *HTTPServer) error {
+	srv := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := srv.Shutdown(shutdown)
+	handler.Close()
+	return err
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"querycraft/pkg/qcparser/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCSV = "id,name,amount\n1,alice,1.5\n2,bob,2.5\n3,carol,3.5\n"
+
+func newTestServer(t *testing.T, config HTTPConfig) (*HTTPServer, *httptest.Server) {
+	t.Helper()
+	if config.TempDir == "" {
+		config.TempDir = t.TempDir()
+	}
+	srv := NewHTTPServer(config)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Close()
+	})
+	return srv, ts
+}
+
+// responseCode decodes the code of an error response
+func responseCode(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	var body types.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	return body.Error.Code
+}
+
+func TestConvertSync(t *testing.T) {
+	_, ts := newTestServer(t, DefaultHTTPConfig())
+
+	resp, err := http.Post(ts.URL+"/convert", "text/csv", strings.NewReader(testCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if !strings.Contains(string(body), name) {
+			t.Errorf("output has no row for %s:\n%s", name, body)
+		}
+	}
+
+	// Trailers are only set once the body is read
+	if rows := resp.Trailer.Get("X-Rows-Written"); rows != "3" {
+		t.Errorf("X-Rows-Written = %q, want 3", rows)
+	}
+	if msg := resp.Trailer.Get("X-Error"); msg != "" {
+		t.Errorf("X-Error = %q", msg)
+	}
+}
+
+func TestConvertInvalidOptions(t *testing.T) {
+	_, ts := newTestServer(t, DefaultHTTPConfig())
+
+	resp, err := http.Post(ts.URL+`/convert?options={"ragged_rows":"drop"}`, "text/csv", strings.NewReader(testCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if code := responseCode(t, resp); code != "INVALID_OPTIONS" {
+		t.Errorf("code %s, want INVALID_OPTIONS", code)
+	}
+}
+
+func TestConvertUploadTooLarge(t *testing.T) {
+	config := DefaultHTTPConfig()
+	config.MaxUploadBytes = 16
+	_, ts := newTestServer(t, config)
+
+	resp, err := http.Post(ts.URL+"/convert?async=1", "text/csv", strings.NewReader(testCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+}
+
+// startJob posts an asynchronous conversion and returns its status
+func startJob(t *testing.T, ts *httptest.Server) JobStatus {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/convert?async=1", "text/csv", strings.NewReader(testCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	var status JobStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if loc := resp.Header.Get("Location"); loc != "/jobs/"+status.ID {
+		t.Errorf("Location = %q, want /jobs/%s", loc, status.ID)
+	}
+	return status
+}
+
+// readEvents reads Server-Sent Events until the stream ends
+func readEvents(t *testing.T, body io.Reader) (names []string, last JobStatus) {
+	t.Helper()
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			names = append(names, name)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			if err := json.Unmarshal([]byte(data), &last); err != nil {
+				t.Fatalf("event data %q: %v", data, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names, last
+}
+
+func TestConvertAsync(t *testing.T) {
+	config := DefaultHTTPConfig()
+	config.TempDir = t.TempDir()
+	srv, ts := newTestServer(t, config)
+
+	status := startJob(t, ts)
+	if status.State != JobRunning {
+		t.Errorf("state %s, want %s", status.State, JobRunning)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/jobs/"+status.ID, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// Progress events while running, then one event named after the end state
+	names, last := readEvents(t, resp.Body)
+	if len(names) == 0 {
+		t.Fatal("no events")
+	}
+	for _, name := range names[:len(names)-1] {
+		if name != "progress" {
+			t.Errorf("event %q before the end, want progress", name)
+		}
+	}
+	if end := names[len(names)-1]; end != JobDone {
+		t.Fatalf("final event %q, want %s (error %+v)", end, JobDone, last.Error)
+	}
+	if last.Result == nil || last.Result.RowsWritten != 3 {
+		t.Errorf("result %+v, want 3 rows", last.Result)
+	}
+	if last.Result != nil && last.Result.DJSONPath != "" {
+		t.Errorf("result exposes the server path %s", last.Result.DJSONPath)
+	}
+
+	// Plain status without the event stream
+	resp, err = http.Get(ts.URL + "/jobs/" + status.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plain JobStatus
+	json.NewDecoder(resp.Body).Decode(&plain)
+	resp.Body.Close()
+	if plain.State != JobDone {
+		t.Errorf("state %s, want %s", plain.State, JobDone)
+	}
+
+	resp, err = http.Get(ts.URL + "/jobs/" + status.ID + "/output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(output), "carol") {
+		t.Errorf("output status %d:\n%s", resp.StatusCode, output)
+	}
+
+	// Close deletes the job and its files
+	srv.Close()
+	resp, err = http.Get(ts.URL + "/jobs/" + status.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status %d after Close, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	entries, err := os.ReadDir(config.TempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("%s left in the temp dir after Close", entry.Name())
+	}
+}
+
+func TestJobDelete(t *testing.T) {
+	_, ts := newTestServer(t, DefaultHTTPConfig())
+	status := startJob(t, ts)
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/jobs/"+status.ID, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	for _, path := range []string{"/jobs/" + status.ID, "/jobs/" + status.ID + "/output"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := responseCode(t, resp); resp.StatusCode != http.StatusNotFound || code != "JOB_NOT_FOUND" {
+			t.Errorf("GET %s after delete: %d %s", path, resp.StatusCode, code)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestBusy(t *testing.T) {
+	srv := NewHTTPServer(HTTPConfig{MaxConcurrent: 1, TempDir: t.TempDir()})
+	defer srv.Close()
+
+	// A conversion blocked on its upload holds the only slot
+	upload, uploadWriter := io.Pipe()
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.ServeHTTP(first, httptest.NewRequest(http.MethodPost, "/convert", upload))
+	}()
+	// The write returns once the handler is reading the upload
+	if _, err := io.WriteString(uploadWriter, "id,name\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/convert", "/detect", "/preview"} {
+		busy := httptest.NewRecorder()
+		srv.ServeHTTP(busy, httptest.NewRequest(http.MethodPost, path, strings.NewReader(testCSV)))
+		if busy.Code != http.StatusTooManyRequests {
+			t.Errorf("%s status %d, want %d", path, busy.Code, http.StatusTooManyRequests)
+		}
+		if retry := busy.Header().Get("Retry-After"); retry == "" {
+			t.Errorf("%s has no Retry-After", path)
+		}
+		var body types.ErrorResponse
+		json.NewDecoder(busy.Body).Decode(&body)
+		if body.Error.Code != "BUSY" {
+			t.Errorf("%s code %s, want BUSY", path, body.Error.Code)
+		}
+	}
+
+	io.WriteString(uploadWriter, "1,a\n")
+	uploadWriter.Close()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("blocked conversion did not finish")
+	}
+	if first.Code != http.StatusOK {
+		t.Fatalf("first conversion status %d: %s", first.Code, first.Body)
+	}
+
+	// The slot is free again
+	again := httptest.NewRecorder()
+	srv.ServeHTTP(again, httptest.NewRequest(http.MethodPost, "/detect", strings.NewReader(testCSV)))
+	if again.Code != http.StatusOK {
+		t.Errorf("status %d once the slot is free: %s", again.Code, again.Body)
+	}
+}
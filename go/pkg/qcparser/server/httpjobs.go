@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"querycraft/pkg/qcparser/types"
+	"sync"
+	"time"
+)
+
+// Job states
+const (
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// JobStatus is the state of an asynchronous conversion
+type JobStatus struct {
+	ID       string               `json:"id"`
+	State    string               `json:"state"` // running | done | failed | cancelled
+	Progress types.Progress       `json:"progress"`
+	Result   *types.ConvertResult `json:"result,omitempty"`
+	Error    *JobErrorInfo        `json:"error,omitempty"`
+	Started  time.Time            `json:"started"`
+	Finished *time.Time           `json:"finished,omitempty"`
+}
+
+// JobErrorInfo describes why a job failed
+type JobErrorInfo struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// httpJob is an asynchronous conversion and its files
+type httpJob struct {
+	mu      sync.Mutex
+	status  JobStatus
+	changed chan struct{} // Closed and replaced on every update
+	cancel  context.CancelFunc
+	input   string
+	output  string
+}
+
+// snapshot returns the current status and a channel closed on the next update
+func (j *httpJob) snapshot() (JobStatus, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status, j.changed
+}
+
+// update applies fn to the status and wakes up the watchers
+func (j *httpJob) update(fn func(*JobStatus)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.status)
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// finish records the outcome of the job
+func (j *httpJob) finish(result *types.ConvertResult, err error) {
+	j.update(func(s *JobStatus) {
+		now := time.Now()
+		s.Finished = &now
+		switch {
+		case err == nil:
+			s.State = JobDone
+			s.Result = result
+		case s.State == JobCancelled:
+		default:
+			s.State = JobFailed
+			s.Error = &JobErrorInfo{Code: errorCode(err), Message: err.Error()}
+		}
+	})
+}
+
+// expired reports whether the job finished more than ttl ago
+func (j *httpJob) expired(ttl time.Duration, now time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status.Finished != nil && now.Sub(*j.status.Finished) > ttl
+}
+
+// jobStore holds the asynchronous conversions of an HTTP server
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*httpJob
+	ttl  time.Duration // Finished jobs older than this are removed; 0 keeps them
+}
+
+func newJobStore(ttl time.Duration) *jobStore {
+	return &jobStore{jobs: make(map[string]*httpJob), ttl: ttl}
+}
+
+// expire removes the jobs that finished more than the TTL ago, with their files
+func (s *jobStore) expire() {
+	if s.ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	ids := make([]string, 0)
+	for id, job := range s.jobs {
+		if job.expired(s.ttl, now) {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range ids {
+		s.remove(id)
+	}
+}
+
+// add registers a new running job
+func (s *jobStore) add(cancel context.CancelFunc, input, output string) *httpJob {
+	s.expire()
+
+	job := &httpJob{
+		status:  JobStatus{ID: newJobID(), State: JobRunning, Started: time.Now()},
+		changed: make(chan struct{}),
+		cancel:  cancel,
+		input:   input,
+		output:  output,
+	}
+
+	s.mu.Lock()
+	s.jobs[job.status.ID] = job
+	s.mu.Unlock()
+	return job
+}
+
+func (s *jobStore) get(id string) (*httpJob, bool) {
+	s.expire()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// remove cancels a job and deletes its files
+func (s *jobStore) remove(id string) bool {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	delete(s.jobs, id)
+	s.mu.Unlock()
+
+	if ok {
+		job.update(func(s *JobStatus) {
+			if s.State == JobRunning {
+				s.State = JobCancelled
+			}
+		})
+		job.cancel()
+		os.Remove(job.input)
+		os.Remove(job.output)
+		os.Remove(types.ManifestPath(job.output))
+	}
+	return ok
+}
+
+// removeAll cancels every job and deletes their files
+func (s *jobStore) removeAll() {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.jobs))
+	for id := range s.jobs {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	for _, id := range ids {
+		s.remove(id)
+	}
+}
+
+// newJobID returns a random job identifier
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Jobs tracks running jobs so they can be cancelled by id
+type Jobs struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// NewJobs returns an empty job registry
+func NewJobs() *Jobs {
+	return &Jobs{cancels: make(map[string]context.CancelFunc)}
+}
+
+// ErrJobExists is returned when a running job already has the id
+var ErrJobExists = errors.New("a running job has this id")
+
+// Start registers a job and returns its context and a function to call when
+// it ends. Jobs without an id cannot be cancelled; an id can only be used
+// by one running job at a time.
+func (j *Jobs) Start(ctx context.Context, id string) (context.Context, func(), error) {
+	if id == "" {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+
+	j.mu.Lock()
+	if _, ok := j.cancels[id]; ok {
+		j.mu.Unlock()
+		return nil, nil, ErrJobExists
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	j.cancels[id] = cancel
+	j.mu.Unlock()
+
+	return ctx, func() {
+		j.mu.Lock()
+		delete(j.cancels, id)
+		j.mu.Unlock()
+		cancel()
+	}, nil
+}
+
+// Cancel cancels a running job and reports whether it was found
+func (j *Jobs) Cancel(id string) bool {
+	j.mu.Lock()
+	cancel, ok := j.cancels[id]
+	j.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+	return ok
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/profiler"
+	"querycraft/pkg/qcparser/types"
+)
+
+// jobParams are the params shared by the detect, preview, convert and profile methods
+type jobParams struct {
+	File    string          `json:"file"`
+	Output  string          `json:"output,omitempty"`  // convert only
+	Options json.RawMessage `json:"options,omitempty"` // Overrides of types.DefaultOptions
+}
+
+// PreviewResult is the result of the preview method
+type PreviewResult struct {
+	Columns []types.Column `json:"columns"`
+	Preview types.Preview  `json:"preview"`
+}
+
+// jobError is a job failure carrying the CLI error code that matches it
+type jobError struct {
+	code string
+	err  error
+}
+
+func (e *jobError) Error() string {
+	return e.err.Error()
+}
+
+func (e *jobError) Unwrap() error {
+	return e.err
+}
+
+// dispatch runs a method and returns its result
+func dispatch(ctx context.Context, method string, raw json.RawMessage, progress types.ProgressFunc) (interface{}, error) {
+	switch method {
+	case "detect", "preview", "convert", "profile":
+	default:
+		return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + method}
+	}
+
+	p, opts, err := parseParams(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case "detect":
+		result, err := detector.Detect(p.File, opts)
+		if err != nil {
+			return nil, failed("DETECTION_FAILED", err)
+		}
+		result, err = qcparser.PreviewTransforms(result, opts)
+		return result, failed("TRANSFORM_INVALID", err)
+	case "preview":
+		result, err := detector.Detect(p.File, opts)
+		if err != nil {
+			return nil, failed("DETECTION_FAILED", err)
+		}
+		if result, err = qcparser.PreviewTransforms(result, opts); err != nil {
+			return nil, failed("TRANSFORM_INVALID", err)
+		}
+		return &PreviewResult{Columns: result.Columns, Preview: result.Preview}, nil
+	case "convert":
+		if p.Output == "" {
+			return nil, invalidParams("convert requires an output path")
+		}
+		result, err := qcparser.ConvertContext(ctx, p.File, p.Output, opts, progress)
+		return result, failed("CONVERSION_FAILED", err)
+	default:
+		result, err := profiler.ProfileContext(ctx, p.File, opts, progress)
+		return result, failed("PROFILE_FAILED", err)
+	}
+}
+
+// parseParams decodes job params, applying option overrides to the defaults
+func parseParams(raw json.RawMessage) (*jobParams, *types.Options, error) {
+	var p jobParams
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, nil, invalidParams("invalid params: %v", err)
+	}
+	if p.File == "" {
+		return nil, nil, invalidParams("params.file is required")
+	}
+
+	opts, err := decodeOptions(p.Options)
+	if err != nil {
+		return nil, nil, invalidParams("%v", err)
+	}
+	return &p, opts, nil
+}
+
+// decodeOptions applies JSON option overrides to the default options
+func decodeOptions(raw []byte) (*types.Options, error) {
+	opts := types.DefaultOptions()
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &opts); err != nil {
+			return nil, fmt.Errorf("invalid options: %w", err)
+		}
+	}
+	if !types.IsRaggedPolicy(opts.RaggedRows) {
+		return nil, fmt.Errorf("unknown ragged row policy: %s", opts.RaggedRows)
+	}
+	if !types.IsSamplingStrategy(opts.Sampling) {
+		return nil, fmt.Errorf("unknown sampling strategy: %s", opts.Sampling)
+	}
+	if !types.IsLocale(opts.Locale) {
+		return nil, fmt.Errorf("unknown locale: %s", opts.Locale)
+	}
+	if !types.IsDedupeKeep(opts.DedupeKeep) {
+		return nil, fmt.Errorf("unknown dedupe keep choice: %s", opts.DedupeKeep)
+	}
+	if err := qcparser.CheckTransforms(opts.Transforms); err != nil {
+		return nil, err
+	}
+	// The salt is a secret, so it comes from the server environment
+	opts.MaskSalt = os.Getenv("QCPARSER_MASK_SALT")
+	if err := qcparser.CheckMasks(opts.Masks, opts.MaskSalt); err != nil {
+		return nil, err
+	}
+	for _, key := range opts.PartitionBy {
+		if !types.IsPartitionBucket(key.Bucket) {
+			return nil, fmt.Errorf("unknown partition bucket: %s", key.Bucket)
+		}
+	}
+	if opts.Schema != nil {
+		if err := detector.ValidateSchema(opts.Schema); err != nil {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+	}
+	return &opts, nil
+}
+
+// failed tags a job error with its error code; nil stays nil
+func failed(code string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, detector.ErrSchemaMismatch):
+		code = "SCHEMA_MISMATCH"
+	case errors.Is(err, os.ErrNotExist):
+		code = "FILE_NOT_FOUND"
+	}
+	return &jobError{code: code, err: err}
+}
+
+// errorCode returns the CLI error code of a job error
+func errorCode(err error) string {
+	var jobErr *jobError
+	if errors.As(err, &jobErr) {
+		return jobErr.code
+	}
+	return "INTERNAL_ERROR"
+}
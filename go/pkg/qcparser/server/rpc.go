@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"querycraft/pkg/qcparser/types"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeJobFailed      = -32000 // A detect, convert, preview or profile job failed
+	CodeCancelled      = -32800 // The request was cancelled
+)
+
+// Request is a JSON-RPC 2.0 request or notification
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // Absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC 2.0 response
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Notification is a JSON-RPC 2.0 notification sent by the server
+type Notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// Error is a JSON-RPC 2.0 error object
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// RPCServer serves JSON-RPC 2.0 over a pair of streams, one message per line.
+// Requests run concurrently; each can be cancelled with the cancel method.
+type RPCServer struct {
+	jobs *Jobs
+
+	mu  sync.Mutex // Guards enc
+	enc *json.Encoder
+
+	wg sync.WaitGroup
+}
+
+// NewRPCServer returns a server writing responses and notifications to w
+func NewRPCServer(w io.Writer) *RPCServer {
+	return &RPCServer{jobs: NewJobs(), enc: json.NewEncoder(w)}
+}
+
+// Serve reads requests from r until it is exhausted or ctx is cancelled,
+// then waits for the running requests to finish
+func (s *RPCServer) Serve(ctx context.Context, r io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			// The stream cannot be resynchronized after a syntax error
+			s.reply(nil, nil, &Error{Code: CodeParseError, Message: err.Error()})
+			break
+		}
+
+		var req Request
+		if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+			s.reply(req.ID, nil, &Error{Code: CodeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"})
+			continue
+		}
+
+		// Jobs are keyed by the request id so they can be cancelled, which
+		// needs the ids of running requests to be unique
+		jobCtx, done := ctx, func() {}
+		if req.Method != "cancel" {
+			var err error
+			if jobCtx, done, err = s.jobs.Start(ctx, string(req.ID)); err != nil {
+				s.reply(req.ID, nil, &Error{Code: CodeInvalidRequest, Message: fmt.Sprintf("request id %s is already used by a running request", req.ID)})
+				continue
+			}
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer done()
+			s.handle(jobCtx, req)
+		}()
+	}
+
+	s.wg.Wait()
+	return ctx.Err()
+}
+
+// handle runs one request in the job context ctx and sends its response
+func (s *RPCServer) handle(ctx context.Context, req Request) {
+	var result interface{}
+	var err error
+
+	if req.Method == "cancel" {
+		result, err = s.cancel(req.Params)
+	} else {
+		progress := func(p types.Progress) {
+			s.notify("progress", map[string]interface{}{"id": req.ID, "progress": p})
+		}
+		result, err = dispatch(ctx, req.Method, req.Params, progress)
+	}
+
+	// Notifications get no response
+	if req.ID == nil {
+		return
+	}
+	if err != nil {
+		s.reply(req.ID, nil, rpcError(err))
+		return
+	}
+	s.reply(req.ID, result, nil)
+}
+
+// cancel cancels the job started by the request with the given id
+func (s *RPCServer) cancel(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.ID == nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "cancel requires the id of a request"}
+	}
+	return map[string]bool{"cancelled": s.jobs.Cancel(string(p.ID))}, nil
+}
+
+func (s *RPCServer) reply(id json.RawMessage, result interface{}, rpcErr *Error) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	s.send(Response{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr})
+}
+
+func (s *RPCServer) notify(method string, params interface{}) {
+	s.send(Notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// send writes one message; concurrent requests share the output stream
+func (s *RPCServer) send(v interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(v)
+}
+
+// rpcError maps a job error to a JSON-RPC error
+func rpcError(err error) *Error {
+	var rpcErr *Error
+	switch {
+	case errors.As(err, &rpcErr):
+		return rpcErr
+	case errors.Is(err, context.Canceled):
+		return &Error{Code: CodeCancelled, Message: "request cancelled"}
+	default:
+		return &Error{Code: CodeJobFailed, Message: err.Error(), Data: map[string]string{"code": errorCode(err)}}
+	}
+}
+
+// invalidParams reports params that could not be decoded or are incomplete
+func invalidParams(format string, args ...interface{}) error {
+	return &Error{Code: CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,60 @@
+package qcparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"querycraft/pkg/qcparser/internal/transform"
+	"querycraft/pkg/qcparser/types"
+)
+
+// LoadTransforms reads a transform spec file and checks its steps
+func LoadTransforms(path string) ([]types.TransformStep, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec types.TransformSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid transform spec %s: %w", path, err)
+	}
+	if err := transform.Check(spec.Steps); err != nil {
+		return nil, fmt.Errorf("invalid transform spec %s: %w", path, err)
+	}
+	return spec.Steps, nil
+}
+
+// CheckTransforms reports errors in transform steps before any input is
+// read; column names are checked once they are known
+func CheckTransforms(steps []types.TransformStep) error {
+	return transform.Check(steps)
+}
+
+// PreviewTransforms applies opts.Transforms to a detection result, returning
+// the columns and preview rows the conversion would write
+func PreviewTransforms(detected *types.DetectResponse, opts *types.Options) (*types.DetectResponse, error) {
+	pipeline, err := transform.Compile(detected, opts.Transforms)
+	if err != nil || pipeline == nil {
+		return detected, err
+	}
+	return pipeline.Config(), nil
+}
+
+// compileTransforms resolves opts.Transforms against the detected columns and
+// returns the columns of the transformed rows
+func compileTransforms(detected *types.DetectResponse, opts *types.Options) (*transform.Pipeline, *types.DetectResponse, error) {
+	pipeline, err := transform.Compile(detected, opts.Transforms)
+	if err != nil || pipeline == nil {
+		return nil, detected, err
+	}
+	return pipeline, pipeline.Config(), nil
+}
+
+// transformRows applies the pipeline to the rows when there is one
+func transformRows(rows <-chan map[string]string, pipeline *transform.Pipeline) <-chan map[string]string {
+	if pipeline == nil {
+		return rows
+	}
+	return transform.Rows(rows, pipeline)
+}
@@ -0,0 +1,32 @@
+package types
+
+// SourceFileColumn receives the input path of each row in multi-file conversions
+const SourceFileColumn = "_source_file"
+
+// DatasetResult is the result of converting several inputs into one dataset
+type DatasetResult struct {
+	DJSONPath    string              `json:"djson_path,omitempty"` // Empty when each input gets its own DJSON
+	Columns      []Column            `json:"columns"`              // Reconciled schema shared by every output
+	Inputs       []InputResult       `json:"inputs"`
+	Incompatible []IncompatibleInput `json:"incompatible,omitempty"`
+	Issues       []Issue             `json:"issues,omitempty"`
+	RowsWritten  int64               `json:"rows_written"`
+	BytesWritten int64               `json:"bytes_written"`
+	DurationMs   int64               `json:"duration_ms"`
+}
+
+// InputResult reports the conversion of one input of a dataset
+type InputResult struct {
+	Path         string       `json:"path"`
+	DJSONPath    string       `json:"djson_path,omitempty"` // Set when each input gets its own DJSON
+	RowsWritten  int64        `json:"rows_written"`
+	BytesWritten int64        `json:"bytes_written"`
+	Errors       []string     `json:"errors,omitempty"`
+	RaggedRows   RaggedCounts `json:"ragged_rows"`
+}
+
+// IncompatibleInput is an input left out of a dataset
+type IncompatibleInput struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
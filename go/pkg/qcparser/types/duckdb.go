@@ -0,0 +1,10 @@
+package types
+
+// DuckDBSchema contains DuckDB statements that load data with the detected schema
+type DuckDBSchema struct {
+	Table       string `json:"table"`
+	Target      string `json:"target"`       // djson | source
+	CreateTable string `json:"create_table"` // CREATE TABLE with exact column types
+	ReadClause  string `json:"read_clause"`  // read_json(...) or read_csv(...) with explicit columns
+	Load        string `json:"load"`         // INSERT INTO the table from the read clause
+}
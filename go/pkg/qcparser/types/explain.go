@@ -0,0 +1,59 @@
+package types
+
+// Explanation contains the evidence behind a CSV detection, returned when Options.Explain is set
+type Explanation struct {
+	Candidates []DelimiterCandidate `json:"candidates"`
+	Decision   DelimiterDecision    `json:"decision"`
+	Header     HeaderEvidence       `json:"header"`
+	Columns    []ColumnVotes        `json:"columns"`
+	Heuristics Heuristics           `json:"heuristics"` // Weights and thresholds in effect
+}
+
+// DelimiterCandidate contains the statistics and score of one delimiter candidate
+type DelimiterCandidate struct {
+	Delimiter         string   `json:"delimiter"`
+	ModeColumns       int      `json:"mode_columns"`
+	ModeCoverage      float64  `json:"mode_coverage"`
+	FieldCountStdDev  float64  `json:"field_count_stddev"`
+	InvalidRate       float64  `json:"invalid_rate"`
+	QuoteAffectedRate float64  `json:"quote_affected_rate"`
+	TotalLines        int      `json:"total_lines"`
+	ValidLines        int      `json:"valid_lines"`
+	Score             float64  `json:"score"`
+	Pass              bool     `json:"pass"`
+	Reasons           []string `json:"reasons"` // Why the candidate passed or failed the constraints
+}
+
+// DelimiterDecision describes how the winning delimiter was chosen
+type DelimiterDecision struct {
+	Winner         string  `json:"winner"`
+	RunnerUp       string  `json:"runner_up,omitempty"`
+	ScoreGap       float64 `json:"score_gap"` // Winner score minus runner-up score
+	Ambiguous      bool    `json:"ambiguous"`
+	EligibleCount  int     `json:"eligible_count"`
+	CandidateCount int     `json:"candidate_count"`
+}
+
+// HeaderEvidence compares the first table row with the inferred column types
+type HeaderEvidence struct {
+	HasHeader bool         `json:"has_header"`
+	Reason    string       `json:"reason"`
+	Cells     []HeaderCell `json:"cells"`
+}
+
+// HeaderCell is one cell of the candidate header row
+type HeaderCell struct {
+	Value      string `json:"value"`
+	Type       string `json:"type"`        // Type inferred for the cell alone, NULL when empty
+	ColumnType string `json:"column_type"` // Type inferred for the rest of the column
+	Differs    bool   `json:"differs"`
+}
+
+// ColumnVotes contains the per-cell type votes behind a column's inferred type
+type ColumnVotes struct {
+	Index  int            `json:"index"`
+	Type   string         `json:"type"`
+	Format string         `json:"format,omitempty"`
+	Votes  map[string]int `json:"votes"`
+	Nulls  int            `json:"nulls"`
+}
@@ -0,0 +1,35 @@
+package types
+
+// Kinds of FollowEvent
+const (
+	FollowAppended  = "appended"
+	FollowRotated   = "rotated"
+	FollowTruncated = "truncated"
+)
+
+// FollowEvent reports a change while following a growing input
+type FollowEvent struct {
+	Type         string       `json:"type"`
+	Offset       int64        `json:"offset"`        // Input bytes converted so far
+	RowsAppended int64        `json:"rows_appended"` // Rows added to the output by this event
+	RowsWritten  int64        `json:"rows_written"`  // Rows in the output
+	BytesWritten int64        `json:"bytes_written"` // Size of the output
+	Errors       []string     `json:"errors,omitempty"`
+	RaggedRows   RaggedCounts `json:"ragged_rows"`
+}
+
+// FollowFunc receives the events of a followed conversion
+type FollowFunc func(FollowEvent)
+
+// FollowState is persisted next to the output so a restarted follow
+// resumes at the last converted record
+type FollowState struct {
+	Offset       int64          `json:"offset"`
+	Line         int            `json:"line"`        // Input lines converted so far
+	Fingerprint  string         `json:"fingerprint"` // Hash of the input's first bytes, to spot a replaced input
+	HeadBytes    int64          `json:"head_bytes"`  // Number of bytes hashed into Fingerprint
+	RowsWritten  int64          `json:"rows_written"`
+	RowsFiltered int64          `json:"rows_filtered"`
+	BytesWritten int64          `json:"bytes_written"`
+	Config       DetectResponse `json:"config"` // Detection result reused for every appended record
+}
@@ -0,0 +1,66 @@
+package types
+
+// Heuristics contains the tunable thresholds and weights of CSV detection
+type Heuristics struct {
+	CoverageWeight   float64 `json:"coverage_weight"`    // Reward for lines sharing the mode field count
+	SpreadWeight     float64 `json:"spread_weight"`      // Penalty for field count standard deviation
+	InvalidWeight    float64 `json:"invalid_weight"`     // Penalty for lines with unterminated quotes
+	QuoteWeight      float64 `json:"quote_weight"`       // Reward for delimiters seen inside quotes
+	MinModeCoverage  float64 `json:"min_mode_coverage"`  // Share of valid lines a delimiter's mode must cover
+	AmbiguityEpsilon float64 `json:"ambiguity_epsilon"`  // Score gap below which winner and runner-up are ambiguous
+	MaxInvalidRate   float64 `json:"max_invalid_rate"`   // Invalid line rate above which HIGH_INVALID_RATE is reported
+	MinPIIShare      float64 `json:"min_pii_share"`      // Share of a column's values matching a PII pattern above which it is flagged
+	MinSemanticShare float64 `json:"min_semantic_share"` // Share of a column's values needed to give it a semantic type
+}
+
+// DefaultHeuristics returns the default detection heuristics
+func DefaultHeuristics() Heuristics {
+	return Heuristics{
+		CoverageWeight:   0.65,
+		SpreadWeight:     0.20,
+		InvalidWeight:    0.15,
+		QuoteWeight:      0.10,
+		MinModeCoverage:  0.80,
+		AmbiguityEpsilon: 0.05,
+		MaxInvalidRate:   0.10,
+		MinPIIShare:      0.80,
+		MinSemanticShare: 0.80,
+	}
+}
+
+// HeuristicConfig sets some detection heuristics, the fields left nil
+// keep their default; an explicit 0 is a value like any other
+type HeuristicConfig struct {
+	CoverageWeight   *float64 `json:"coverage_weight,omitempty"`
+	SpreadWeight     *float64 `json:"spread_weight,omitempty"`
+	InvalidWeight    *float64 `json:"invalid_weight,omitempty"`
+	QuoteWeight      *float64 `json:"quote_weight,omitempty"`
+	MinModeCoverage  *float64 `json:"min_mode_coverage,omitempty"`
+	AmbiguityEpsilon *float64 `json:"ambiguity_epsilon,omitempty"`
+	MaxInvalidRate   *float64 `json:"max_invalid_rate,omitempty"`
+	MinPIIShare      *float64 `json:"min_pii_share,omitempty"`
+	MinSemanticShare *float64 `json:"min_semantic_share,omitempty"`
+}
+
+// Apply returns h with the fields set by o replaced
+func (o HeuristicConfig) Apply(h Heuristics) Heuristics {
+	for _, field := range []struct {
+		value    *float64
+		override *float64
+	}{
+		{&h.CoverageWeight, o.CoverageWeight},
+		{&h.SpreadWeight, o.SpreadWeight},
+		{&h.InvalidWeight, o.InvalidWeight},
+		{&h.QuoteWeight, o.QuoteWeight},
+		{&h.MinModeCoverage, o.MinModeCoverage},
+		{&h.AmbiguityEpsilon, o.AmbiguityEpsilon},
+		{&h.MaxInvalidRate, o.MaxInvalidRate},
+		{&h.MinPIIShare, o.MinPIIShare},
+		{&h.MinSemanticShare, o.MinSemanticShare},
+	} {
+		if field.override != nil {
+			*field.value = *field.override
+		}
+	}
+	return h
+}
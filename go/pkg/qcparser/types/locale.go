@@ -0,0 +1,24 @@
+package types
+
+// Locales of numbers and month names in the source values. A locale sets
+// the decimal and thousand separators of numeric columns and the month
+// names of date columns.
+const (
+	LocaleEN = "en" // 1,234.56 and English months (default)
+	LocaleDE = "de" // 1.234,56 and German months
+	LocaleFR = "fr" // 1 234,56 and French months
+	LocaleES = "es" // 1.234,56 and Spanish months
+	LocaleIT = "it" // 1.234,56 and Italian months
+	LocalePT = "pt" // 1.234,56 and Portuguese months
+	LocaleNL = "nl" // 1.234,56 and Dutch months
+)
+
+// IsLocale reports whether l is a known locale, or empty
+func IsLocale(l string) bool {
+	switch l {
+	case "", LocaleEN, LocaleDE, LocaleFR, LocaleES, LocaleIT, LocalePT, LocaleNL:
+		return true
+	default:
+		return false
+	}
+}
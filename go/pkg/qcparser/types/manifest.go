@@ -0,0 +1,43 @@
+package types
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// ToolVersion is the parser version recorded in manifests
+const ToolVersion = "1.0.0"
+
+// Manifest records how a DJSON output was produced; it is written next to
+// the output, see ManifestPath
+type Manifest struct {
+	ToolVersion  string           `json:"tool_version"`
+	Sources      []SourceInfo     `json:"sources"`
+	Detection    *DetectResponse  `json:"detection"` // Schema the rows were converted with
+	Options      Options          `json:"options"`
+	RowsWritten  int64            `json:"rows_written"`
+	RowsFiltered int64            `json:"rows_filtered"`
+	BytesWritten int64            `json:"bytes_written"`
+	Files        []OutputFile     `json:"files,omitempty"` // Set when the output is sharded or partitioned
+	CastFailures map[string]int64 `json:"cast_failures"`   // Non-null values per column that did not parse as the column type
+	StartedAt    time.Time        `json:"started_at"`
+	FinishedAt   time.Time        `json:"finished_at"`
+
+	DuplicatesRemoved int64        `json:"duplicates_removed"`
+	RaggedRows        RaggedCounts `json:"ragged_rows"`
+	Errors            []string     `json:"errors,omitempty"` // Rows the reader could not parse
+}
+
+// SourceInfo identifies an input of a conversion
+type SourceInfo struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// ManifestPath returns the path of the manifest of a DJSON output
+func ManifestPath(djsonPath string) string {
+	// A partitioned output directory gets its manifest beside it
+	return filepath.Clean(djsonPath) + ".meta.json"
+}
@@ -2,15 +2,121 @@ package types
 
 // Options contains configuration for file detection
 type Options struct {
-	Format          string   `json:"force_format"`
-	HasHeader       bool     `json:"has_header"`
-	FieldCount      int      `json:"field_count"`
-	SampleBytes     int64    `json:"sample_bytes"`
-	MaxPreviewRows  int      `json:"max_preview_rows"`
-	Delimiters      []rune   `json:"delimiters"`
-	CommentPrefixes []string `json:"comment_prefixes"`
-	AssumeUTF8      bool     `json:"assume_utf8"`
-	MaxLineBytes    int      `json:"max_line_bytes"`
+	Format          string          `json:"force_format"`
+	HasHeader       bool            `json:"has_header"`
+	FieldCount      int             `json:"field_count"`
+	SampleBytes     int64           `json:"sample_bytes"`
+	MaxPreviewRows  int             `json:"max_preview_rows"`
+	Delimiters      []rune          `json:"delimiters"`
+	CommentPrefixes []string        `json:"comment_prefixes"`
+	AssumeUTF8      bool            `json:"assume_utf8"`
+	MaxLineBytes    int             `json:"max_line_bytes"`
+	SkipRows        int             `json:"skip_rows"`               // -1 = auto-detect preamble
+	RaggedRows      string          `json:"ragged_rows"`             // reject | pad | truncate | extra
+	TopK            int             `json:"top_k"`                   // Frequent values reported by profile
+	HistogramBins   int             `json:"histogram_bins"`          // Numeric histogram buckets reported by profile
+	Schema          *SchemaDoc      `json:"schema,omitempty"`        // Pinned schema, bypasses inference
+	LineColumn      string          `json:"line_column,omitempty"`   // Row key receiving the source line number
+	Explain         bool            `json:"explain"`                 // Include detection evidence in the response
+	Heuristics      HeuristicConfig `json:"heuristics"`              // Delimiter scoring weights and thresholds replacing the defaults
+	SourceColumn    string          `json:"source_column,omitempty"` // Column receiving the input path in multi-file conversions
+	Select          []string        `json:"select,omitempty"`        // Output columns in order; empty keeps all
+	Where           string          `json:"where,omitempty"`         // Row filter expression evaluated on typed values
+	Sampling        string          `json:"sampling"`                // head | stratified | reservoir
+	SampleRegions   int             `json:"sample_regions"`          // Regions read by stratified sampling
+	Locale          string          `json:"locale,omitempty"`        // Locale of every column, overriding per-column detection
+	DedupeOn        []string        `json:"dedupe_on,omitempty"`     // Columns identifying duplicate rows
+	DedupeRows      bool            `json:"dedupe_rows"`             // Drop rows repeating every column of an earlier row
+	DedupeKeep      string          `json:"dedupe_keep,omitempty"`   // first | last row of each key
+	SortBy          []SortKey       `json:"sort_by,omitempty"`       // Output order, compared on typed values
+	Transforms      []TransformStep `json:"transforms,omitempty"`    // Column operations applied to rows before dedupe, sort and write
+	MaxRowsPerFile  int64           `json:"max_rows_per_file"`       // Start a new output file after this many rows; 0 = no limit
+	MaxBytesPerFile int64           `json:"max_bytes_per_file"`      // Start a new output file before exceeding this size; 0 = no limit
+	PartitionBy     []PartitionKey  `json:"partition_by,omitempty"`  // Hive-style directories by column value
+	Masks           []MaskRule      `json:"masks,omitempty"`         // Columns masked before any row is spilled or written
+	MaskSalt        string          `json:"-"`                       // Secret salt of the hash and tokenize masks
+	MemoryBytes     int64           `json:"memory_bytes"`            // Memory held by dedupe, sort and validation of unique values before spilling to disk
+	TempDir         string          `json:"-"`                       // Directory of spill files; empty uses the system default
+	CacheDir        string          `json:"-"`                       // Directory of reusable conversions; empty disables the cache
+	CacheMaxBytes   int64           `json:"-"`                       // Cache size above which the least recently used entries are evicted
+}
+
+// Ragged row policies for records whose field count differs from the header
+const (
+	RaggedReject   = "reject"   // Drop the record (default)
+	RaggedPad      = "pad"      // Fill missing trailing fields with nulls
+	RaggedTruncate = "truncate" // Drop fields beyond the column count
+	RaggedExtra    = "extra"    // Collect fields beyond the column count into ExtraColumn
+)
+
+// Sampling strategies for detection
+const (
+	SampleHead       = "head"       // First SampleBytes of the input (default)
+	SampleStratified = "stratified" // SampleRegions line-aligned windows spread across the file
+	SampleReservoir  = "reservoir"  // The head plus a uniform random sample of lines over the whole file
+)
+
+// IsSamplingStrategy reports whether s is a known sampling strategy
+func IsSamplingStrategy(s string) bool {
+	switch s {
+	case SampleHead, SampleStratified, SampleReservoir:
+		return true
+	default:
+		return false
+	}
+}
+
+// Rows kept among duplicates
+const (
+	DedupeFirst = "first" // The first row of each key, in input order (default)
+	DedupeLast  = "last"  // The last row of each key, in input order
+)
+
+// IsDedupeKeep reports whether k is a known choice of duplicate to keep
+func IsDedupeKeep(k string) bool {
+	return k == DedupeFirst || k == DedupeLast
+}
+
+// SortKey is a column of the output order
+type SortKey struct {
+	Column string `json:"column"`
+	Desc   bool   `json:"desc"`
+}
+
+// PartitionKey is a column whose value, or date bucket, names an output directory
+type PartitionKey struct {
+	Column string `json:"column"`
+	Bucket string `json:"bucket,omitempty"` // year | month | day of a DATE or TIMESTAMP column; empty uses the value
+}
+
+// Date buckets of a PartitionKey
+const (
+	BucketYear  = "year"
+	BucketMonth = "month"
+	BucketDay   = "day"
+)
+
+// IsPartitionBucket reports whether b is a known date bucket, or empty
+func IsPartitionBucket(b string) bool {
+	switch b {
+	case "", BucketYear, BucketMonth, BucketDay:
+		return true
+	default:
+		return false
+	}
+}
+
+// ExtraColumn holds the surplus fields of ragged rows under the RaggedExtra policy
+const ExtraColumn = "_extra"
+
+// IsRaggedPolicy reports whether p is a known ragged row policy
+func IsRaggedPolicy(p string) bool {
+	switch p {
+	case RaggedReject, RaggedPad, RaggedTruncate, RaggedExtra:
+		return true
+	default:
+		return false
+	}
 }
 
 // DefaultOptions returns default detection options
@@ -23,5 +129,13 @@ func DefaultOptions() Options {
 		CommentPrefixes: []string{"#", "//", "--"},
 		AssumeUTF8:      true,
 		MaxLineBytes:    32 << 20, // 32MB guard
+		SkipRows:        -1,
+		RaggedRows:      RaggedReject,
+		TopK:            10,
+		HistogramBins:   20,
+		Sampling:        SampleHead,
+		SampleRegions:   8,
+		DedupeKeep:      DedupeFirst,
+		MemoryBytes:     256 << 20, // 256MB
 	}
 }
@@ -0,0 +1,36 @@
+package types
+
+// Kinds of personal data flagged by detection, reported as PII_<KIND> issues
+const (
+	PIIEmail      = "email"
+	PIIPhone      = "phone"
+	PIICreditCard = "credit_card"
+	PIIIBAN       = "iban"
+	PIIIPAddress  = "ip_address"
+	PIINationalID = "national_id"
+)
+
+// MaskRule masks the values of a column before they are written
+type MaskRule struct {
+	Column   string `json:"column"`
+	Strategy string `json:"strategy"`       // See the Mask* constants
+	Keep     int    `json:"keep,omitempty"` // Trailing characters kept by MaskLast
+}
+
+// Masking strategies
+const (
+	MaskRedact   = "redact"   // Replace the value with a fixed marker
+	MaskHash     = "hash"     // Salted SHA-256 of the value, as hex
+	MaskLast     = "last"     // Keep the last Keep characters, mask the others with *
+	MaskTokenize = "tokenize" // Salted token keeping the layout of the value, equal values get equal tokens
+)
+
+// IsMaskStrategy reports whether s is a known masking strategy
+func IsMaskStrategy(s string) bool {
+	switch s {
+	case MaskRedact, MaskHash, MaskLast, MaskTokenize:
+		return true
+	default:
+		return false
+	}
+}
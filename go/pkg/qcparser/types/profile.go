@@ -0,0 +1,41 @@
+package types
+
+// ProfileResponse is the result of full-file column profiling
+type ProfileResponse struct {
+	Format      string          `json:"format"`
+	Rows        int64           `json:"rows"`
+	InvalidRows int64           `json:"invalid_rows"`
+	Columns     []ColumnProfile `json:"columns"`
+	DurationMs  int64           `json:"duration_ms"`
+}
+
+// ColumnProfile contains statistics for a single column
+type ColumnProfile struct {
+	Name           string         `json:"name"`
+	Type           string         `json:"type"`
+	Count          int64          `json:"count"`         // Non-null values
+	NullCount      int64          `json:"null_count"`    // Empty or null-token values
+	InvalidCount   int64          `json:"invalid_count"` // Values that don't parse as Type
+	DistinctApprox uint64         `json:"distinct_approx"`
+	Min            interface{}    `json:"min,omitempty"` // Number, or RFC 3339 string for timestamps
+	Max            interface{}    `json:"max,omitempty"`
+	Mean           *float64       `json:"mean,omitempty"`
+	StdDev         *float64       `json:"stddev,omitempty"`
+	MinLength      *int           `json:"min_length,omitempty"`
+	MaxLength      *int           `json:"max_length,omitempty"`
+	TopValues      []ValueCount   `json:"top_values"`
+	Histogram      []HistogramBin `json:"histogram,omitempty"`
+}
+
+// ValueCount is a value with its (approximate) number of occurrences
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// HistogramBin is an equal-width bucket of numeric values
+type HistogramBin struct {
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+	Count int64   `json:"count"`
+}
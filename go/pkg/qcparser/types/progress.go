@@ -0,0 +1,11 @@
+package types
+
+// Progress reports how far a long-running job has read its input
+type Progress struct {
+	BytesRead  int64 `json:"bytes_read"`
+	TotalBytes int64 `json:"total_bytes"` // 0 when the input size is unknown
+	Rows       int64 `json:"rows"`
+}
+
+// ProgressFunc receives periodic Progress updates
+type ProgressFunc func(Progress)
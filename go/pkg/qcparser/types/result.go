@@ -2,9 +2,30 @@ package types
 
 // ConvertResult is the result of file conversion to DJSON
 type ConvertResult struct {
-	DJSONPath    string   `json:"djson_path"`
-	RowsWritten  int64    `json:"rows_written"`
-	BytesWritten int64    `json:"bytes_written"`
-	DurationMs   int64    `json:"duration_ms"`
-	Errors       []string `json:"errors,omitempty"` // Collected error messages
+	DJSONPath         string           `json:"djson_path"`
+	RowsWritten       int64            `json:"rows_written"`
+	RowsFiltered      int64            `json:"rows_filtered"`      // Rows dropped by Options.Where
+	DuplicatesRemoved int64            `json:"duplicates_removed"` // Rows dropped by Options.DedupeOn or DedupeRows
+	BytesWritten      int64            `json:"bytes_written"`
+	DurationMs        int64            `json:"duration_ms"`
+	Errors            []string         `json:"errors,omitempty"` // Collected error messages
+	RaggedRows        RaggedCounts     `json:"ragged_rows"`
+	CastFailures      map[string]int64 `json:"cast_failures,omitempty"` // Non-null values per column that did not parse as the column type
+	Files             []OutputFile     `json:"files,omitempty"`         // Every file written, with its rows
+	Cached            bool             `json:"cached"`                  // Output copied from an earlier conversion of the same input
+}
+
+// OutputFile is a DJSON file written by a conversion
+type OutputFile struct {
+	Path  string `json:"path"`
+	Rows  int64  `json:"rows"`
+	Bytes int64  `json:"bytes"`
+}
+
+// RaggedCounts reports how many ragged rows each policy action affected
+type RaggedCounts struct {
+	Rejected  int64 `json:"rejected"`
+	Padded    int64 `json:"padded"`
+	Truncated int64 `json:"truncated"`
+	Collected int64 `json:"collected"` // Rows whose extras went to ExtraColumn
 }
@@ -0,0 +1,57 @@
+package types
+
+// SchemaDoc is a user-supplied schema that replaces inference for recurring feeds
+type SchemaDoc struct {
+	Columns    []SchemaColumn `json:"columns"`
+	Dialect    Dialect        `json:"dialect"`
+	MatchBy    string         `json:"match_by"`            // position (default) | name
+	AllowExtra bool           `json:"allow_extra_columns"` // Keep unknown input columns as TEXT
+
+	// Data contract rules, checked by validate
+	Key     []string `json:"key,omitempty"` // Columns whose combined values must be unique
+	MinRows *int64   `json:"min_rows,omitempty"`
+	MaxRows *int64   `json:"max_rows,omitempty"`
+}
+
+// SchemaColumn declares one expected column
+type SchemaColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Format   string `json:"format,omitempty"`   // strftime format for DATE and TIMESTAMP
+	Locale   string `json:"locale,omitempty"`   // Separators and month names of the source values
+	Nullable *bool  `json:"nullable,omitempty"` // Default true
+	Required *bool  `json:"required,omitempty"` // Default true: the column must exist in the input
+
+	// Data contract rules, checked by validate
+	AllowedValues []string `json:"allowed_values,omitempty"`
+	Pattern       string   `json:"pattern,omitempty"` // Regular expression the whole value must match
+	Min           *float64 `json:"min,omitempty"`     // Inclusive bounds for numeric columns
+	Max           *float64 `json:"max,omitempty"`
+	Unique        bool     `json:"unique,omitempty"`
+}
+
+// Dialect describes how the input file is laid out
+type Dialect struct {
+	Format     string  `json:"format"`    // csv (default), the only format read with a pinned schema
+	Delimiter  string  `json:"delimiter"` // Default ","
+	HasHeader  bool    `json:"has_header"`
+	SkipRows   int     `json:"skip_rows"`
+	FooterRows int     `json:"footer_rows"`
+	Comment    *string `json:"comment,omitempty"`
+}
+
+// Schema column matching modes
+const (
+	MatchByPosition = "position"
+	MatchByName     = "name"
+)
+
+// IsColumnType reports whether t is a supported column type
+func IsColumnType(t string) bool {
+	switch t {
+	case "INT", "DOUBLE", "DATE", "TIMESTAMP", "BOOLEAN", "TEXT", "TEXT[]":
+		return true
+	default:
+		return false
+	}
+}
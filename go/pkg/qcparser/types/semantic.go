@@ -0,0 +1,20 @@
+package types
+
+// Semantic types of a column, inferred on top of its primitive type
+const (
+	SemanticEmail          = "email"
+	SemanticURL            = "url"
+	SemanticIPv4           = "ipv4"
+	SemanticIPv6           = "ipv6"
+	SemanticUUID           = "uuid"
+	SemanticHostname       = "hostname"
+	SemanticMACAddress     = "mac_address"
+	SemanticCountryCode    = "country_code"  // ISO 3166-1 alpha-2
+	SemanticCurrencyCode   = "currency_code" // ISO 4217
+	SemanticPercentage     = "percentage"    // "12.5%"
+	SemanticCurrencyAmount = "currency_amount"
+	SemanticLatitude       = "latitude"
+	SemanticLongitude      = "longitude"
+	SemanticFilePath       = "file_path"
+	SemanticJSON           = "json" // JSON object or array held in text
+)
@@ -0,0 +1,39 @@
+package types
+
+// TransformSpec is a transform file: column operations applied in order to
+// every row between reading and writing
+type TransformSpec struct {
+	Steps []TransformStep `json:"steps"`
+}
+
+// TransformStep is one column operation; the fields used depend on Op
+type TransformStep struct {
+	Op        string   `json:"op"`                  // See the Transform* constants
+	Column    string   `json:"column,omitempty"`    // Input column
+	Columns   []string `json:"columns,omitempty"`   // Input columns of concat and coalesce
+	As        string   `json:"as,omitempty"`        // Output column; empty writes Column in place
+	Into      []string `json:"into,omitempty"`      // Output columns of split
+	Type      string   `json:"type,omitempty"`      // Column type of cast and constant
+	Format    string   `json:"format,omitempty"`    // strftime format of a DATE or TIMESTAMP cast
+	Pattern   string   `json:"pattern,omitempty"`   // Regular expression of regex_extract and replace
+	Group     *int     `json:"group,omitempty"`     // Group kept by regex_extract; default 1, or 0 without groups
+	With      string   `json:"with,omitempty"`      // Replacement of replace, may use $1
+	Separator string   `json:"separator,omitempty"` // Separator of split and concat
+	Value     string   `json:"value,omitempty"`     // Value of constant
+}
+
+// Transform operations
+const (
+	TransformRename       = "rename"        // Rename Column to As
+	TransformCast         = "cast"          // Change the type of Column
+	TransformRegexExtract = "regex_extract" // Keep a group of the first Pattern match, null without a match
+	TransformSplit        = "split"         // Split Column on Separator into the Into columns
+	TransformConcat       = "concat"        // Join Columns with Separator into As
+	TransformReplace      = "replace"       // Replace every Pattern match with With
+	TransformLowercase    = "lowercase"     // Lowercase Column
+	TransformCoalesce     = "coalesce"      // First non-null value of Columns into As
+	TransformConstant     = "constant"      // Set As to Value on every row
+
+	TransformParsePercentage = "parse_percentage" // Parse "12.5%" in Column into the DOUBLE 0.125
+	TransformParseAmount     = "parse_amount"     // Parse "$1,200" in Column into the DOUBLE 1200
+)
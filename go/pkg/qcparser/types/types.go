@@ -7,13 +7,18 @@ type DetectResponse struct {
 	Delimiter  *DelimiterInfo `json:"delimiter,omitempty"`
 	Comment    *string        `json:"comment,omitempty"`
 	HasHeader  bool           `json:"has_header"`
+	SkipRows   int            `json:"skip_rows"`
+	FooterRows int            `json:"footer_rows"`
 	FieldCount int            `json:"field_count"`
 	TrimFields bool           `json:"trim_fields"`
 	Columns    []Column       `json:"columns"`
 	Preview    Preview        `json:"preview"`
 	Confidence float64        `json:"confidence"`
 	Issues     []Issue        `json:"issues"`
+	Metadata   []string       `json:"metadata_lines,omitempty"` // Skipped preamble lines
+	Footer     []string       `json:"footer_lines,omitempty"`   // Excluded trailing summary lines
 	Sampled    SampledMeta    `json:"sampled"`
+	Explain    *Explanation   `json:"explain,omitempty"` // Detection evidence, set when Options.Explain is true
 	DurationMs int64          `json:"duration_ms"`
 }
 
@@ -25,8 +30,14 @@ type DelimiterInfo struct {
 
 // Column represents a detected column's name and type
 type Column struct {
-	Name string `json:"name"`
-	Type string `json:"type"` // INT | DOUBLE | DATE | TIMESTAMP | BOOLEAN | TEXT
+	Name     string `json:"name"`
+	Type     string `json:"type"`               // INT | DOUBLE | DATE | TIMESTAMP | BOOLEAN | TEXT | TEXT[]
+	Format   string `json:"format,omitempty"`   // strftime format of TIMESTAMP values in the source
+	Nullable *bool  `json:"nullable,omitempty"` // Set by a pinned schema; false rejects null rows
+	Locale   string `json:"locale,omitempty"`   // Separators and month names of the source values, see the Locale* constants; empty is en
+
+	SemanticType       string  `json:"semantic_type,omitempty"`       // What the values stand for, see the Semantic* constants
+	SemanticConfidence float64 `json:"semantic_confidence,omitempty"` // Share of the sampled values of the semantic type
 }
 
 // Preview contains sample rows from the file
@@ -40,13 +51,26 @@ type Preview struct {
 type Issue struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+	Lines   []int  `json:"lines,omitempty"` // 1-based line numbers the issue refers to
+
+	Column     string  `json:"column,omitempty"`     // Column the issue refers to
+	Confidence float64 `json:"confidence,omitempty"` // Share of the sampled values supporting the issue
 }
 
 // SampledMeta contains information about the sampled data
 type SampledMeta struct {
-	Lines      int   `json:"lines"`
-	Bytes      int64 `json:"bytes"`
-	DurationMs int64 `json:"duration_ms"`
+	Lines      int            `json:"lines"`
+	Bytes      int64          `json:"bytes"`
+	Strategy   string         `json:"strategy,omitempty"` // head | stratified | reservoir
+	Regions    []SampleRegion `json:"regions,omitempty"`
+	DurationMs int64          `json:"duration_ms"`
+}
+
+// SampleRegion is a byte range of the input that contributed lines to the sample
+type SampleRegion struct {
+	Offset int64 `json:"offset"`
+	Bytes  int64 `json:"bytes"`
+	Lines  int   `json:"lines"` // Lines taken from the region
 }
 
 // ErrorResponse represents an error response
@@ -0,0 +1,19 @@
+package types
+
+// ValidationReport is the result of checking a file against a data contract
+type ValidationReport struct {
+	Valid          bool        `json:"valid"`
+	Rows           int64       `json:"rows"`
+	ViolationCount int64       `json:"violation_count"`
+	Violations     []Violation `json:"violations"`
+	DurationMs     int64       `json:"duration_ms"`
+}
+
+// Violation aggregates every occurrence of one broken rule
+type Violation struct {
+	Rule    string `json:"rule"` // schema | parse | type | not_null | allowed_values | pattern | range | unique | key | row_count
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+	Count   int64  `json:"count"`
+	Lines   []int  `json:"lines,omitempty"` // First offending line numbers
+}
@@ -0,0 +1,153 @@
+package validator
+
+import (
+	"errors"
+	"hash/fnv"
+	"io"
+	"os"
+	"querycraft/pkg/qcparser/internal/spill"
+)
+
+// partitions is the number of spill files values are hashed into once the
+// memory budget is exceeded; each partition is checked on its own
+const partitions = 64
+
+// duplicates finds the repeated values of a unique column or key. Values are
+// compared in full, held in memory up to budget, then hashed into
+// partitions spilled to tempDir and checked once the input is read.
+type duplicates struct {
+	budget  int64
+	tempDir string
+
+	seen map[string]struct{}
+	used int64
+
+	dir   string // Spill directory, set once spilled
+	parts []*spill.Writer
+	err   error // Spill failure; later values are not checked
+}
+
+func newDuplicates(budget int64, tempDir string) *duplicates {
+	return &duplicates{budget: budget, tempDir: tempDir, seen: make(map[string]struct{})}
+}
+
+// add records the value found at line and reports whether it repeats an
+// earlier value. Once spilled, repeats are only reported by finish.
+func (d *duplicates) add(value string, line int) bool {
+	if d.err != nil {
+		return false
+	}
+	if d.parts != nil {
+		d.err = d.partition(&spill.Record{Seq: int64(line), Key: value})
+		return false
+	}
+
+	if _, dup := d.seen[value]; dup {
+		return true
+	}
+	d.seen[value] = struct{}{}
+	d.used += int64(len(value)) + 48
+	if d.used > d.budget {
+		d.err = d.spill()
+	}
+	return false
+}
+
+// spill moves the values seen so far to the partitions
+func (d *duplicates) spill() error {
+	dir, err := os.MkdirTemp(d.tempDir, "qcparser-validate-*")
+	if err != nil {
+		return err
+	}
+	d.dir = dir
+
+	d.parts = make([]*spill.Writer, partitions)
+	for i := range d.parts {
+		if d.parts[i], err = spill.Create(dir); err != nil {
+			return err
+		}
+	}
+
+	// Values seen in memory are markers placed before any later value
+	for value := range d.seen {
+		if err := d.partition(&spill.Record{Seq: -1, Key: value}); err != nil {
+			return err
+		}
+	}
+	d.seen = nil
+	return nil
+}
+
+// partition appends a record to the partition of its value
+func (d *duplicates) partition(rec *spill.Record) error {
+	h := fnv.New32a()
+	h.Write([]byte(rec.Key))
+	return d.parts[h.Sum32()%partitions].Write(rec)
+}
+
+// finish calls report with the line of every repeat left in the spilled
+// partitions, in line order, and deletes the spill files
+func (d *duplicates) finish(report func(line int)) error {
+	if d.dir != "" {
+		defer os.RemoveAll(d.dir)
+	}
+	if d.err != nil || d.parts == nil {
+		return d.err
+	}
+
+	repeats := make([]string, 0, partitions)
+	for _, part := range d.parts {
+		if err := part.Close(); err != nil {
+			return err
+		}
+		path, err := d.repeats(part.Path())
+		if err != nil {
+			return err
+		}
+		os.Remove(part.Path())
+		repeats = append(repeats, path)
+	}
+
+	return spill.Merge(repeats, func(a, b *spill.Record) bool {
+		return a.Seq < b.Seq
+	}, func(rec *spill.Record) error {
+		report(int(rec.Seq))
+		return nil
+	})
+}
+
+// repeats writes the records of a partition whose value came earlier to a
+// new file, in input order
+func (d *duplicates) repeats(path string) (string, error) {
+	reader, err := spill.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	out, err := spill.Create(d.dir)
+	if err != nil {
+		return "", err
+	}
+
+	seen := make(map[string]struct{})
+	for {
+		rec, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			out.Close()
+			return "", err
+		}
+		if _, dup := seen[rec.Key]; !dup {
+			seen[rec.Key] = struct{}{}
+			continue
+		}
+		if err := out.Write(rec); err != nil {
+			out.Close()
+			return "", err
+		}
+	}
+	return out.Path(), out.Close()
+}
@@ -0,0 +1,206 @@
+package validator
+
+import (
+	"fmt"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxViolationLines caps how many line numbers are kept per violation
+const maxViolationLines = 100
+
+// columnRule checks the contract rules of one column
+type columnRule struct {
+	col     types.SchemaColumn
+	layout  string
+	pattern *regexp.Regexp
+	allowed map[string]bool
+	unique  *duplicates
+}
+
+// trackerBudget splits memBytes between the unique columns and the key
+func trackerBudget(contract *types.SchemaDoc, memBytes int64) int64 {
+	trackers := int64(1)
+	for _, col := range contract.Columns {
+		if col.Unique {
+			trackers++
+		}
+	}
+	return memBytes / trackers
+}
+
+// compileRules compiles the column rules; each unique column tracks its
+// values in budget bytes before spilling them to tempDir
+func compileRules(contract *types.SchemaDoc, budget int64, tempDir string) []*columnRule {
+	rules := make([]*columnRule, len(contract.Columns))
+	for i, col := range contract.Columns {
+		rule := &columnRule{col: col}
+		if col.Format != "" {
+			rule.layout = util.StrftimeToLayout(col.Format)
+		}
+		if col.Pattern != "" {
+			// Validated when the contract was loaded
+			rule.pattern = regexp.MustCompile("^(?:" + col.Pattern + ")$")
+		}
+		if len(col.AllowedValues) > 0 {
+			rule.allowed = make(map[string]bool, len(col.AllowedValues))
+			for _, v := range col.AllowedValues {
+				rule.allowed[v] = true
+			}
+		}
+		if col.Unique {
+			rule.unique = newDuplicates(budget, tempDir)
+		}
+		rules[i] = rule
+	}
+	return rules
+}
+
+// check applies the column's rules to a row
+func (r *columnRule) check(row map[string]string, line int, t *tally) {
+	name := r.col.Name
+	raw, present := row[name]
+	value := strings.TrimSpace(raw)
+
+	if !present || detector.IsNullValue(value) {
+		if r.col.Nullable != nil && !*r.col.Nullable {
+			t.add("not_null", name, "value is null", line)
+		}
+		return
+	}
+
+	number, isNumber := detector.ParseNumber(value, r.col.Locale)
+	if !r.fitsType(value, number, isNumber) {
+		t.add("type", name, fmt.Sprintf("value is not a valid %s", r.col.Type), line)
+		return
+	}
+
+	if r.allowed != nil && !r.allowed[value] {
+		t.add("allowed_values", name, "value is not in the allowed list", line)
+	}
+	if r.pattern != nil && !r.pattern.MatchString(value) {
+		t.add("pattern", name, fmt.Sprintf("value does not match %s", r.col.Pattern), line)
+	}
+	if isNumber && (r.col.Type == "INT" || r.col.Type == "DOUBLE") {
+		if (r.col.Min != nil && number < *r.col.Min) || (r.col.Max != nil && number > *r.col.Max) {
+			t.add("range", name, rangeMessage(r.col), line)
+		}
+	}
+	if r.unique != nil && r.unique.add(value, line) {
+		t.add("unique", name, "duplicate value", line)
+	}
+}
+
+// finish reports the duplicate values found after spilling
+func (r *columnRule) finish(t *tally) error {
+	if r.unique == nil {
+		return nil
+	}
+	return r.unique.finish(func(line int) {
+		t.add("unique", r.col.Name, "duplicate value", line)
+	})
+}
+
+// fitsType checks a non-null value against the declared column type
+func (r *columnRule) fitsType(value string, number float64, isNumber bool) bool {
+	switch r.col.Type {
+	case "INT":
+		_, isInt := detector.ParseInteger(value, r.col.Locale)
+		return isInt
+	case "DOUBLE":
+		return isNumber
+	case "BOOLEAN":
+		return detector.IsBoolValue(value)
+	case "DATE", "TIMESTAMP":
+		if r.layout != "" {
+			_, err := time.Parse(r.layout, detector.NormalizeMonths(value, r.col.Locale))
+			return err == nil
+		}
+		return detector.IsDateValue(value, r.col.Locale)
+	default:
+		return true
+	}
+}
+
+func rangeMessage(col types.SchemaColumn) string {
+	low, high := "-inf", "+inf"
+	if col.Min != nil {
+		low = strconv.FormatFloat(*col.Min, 'g', -1, 64)
+	}
+	if col.Max != nil {
+		high = strconv.FormatFloat(*col.Max, 'g', -1, 64)
+	}
+	return fmt.Sprintf("value is outside [%s, %s]", low, high)
+}
+
+// keyTracker detects duplicate composite keys
+type keyTracker struct {
+	columns []string
+	keys    *duplicates
+}
+
+// newKeyTracker tracks the keys in budget bytes before spilling them to tempDir
+func newKeyTracker(columns []string, budget int64, tempDir string) *keyTracker {
+	return &keyTracker{columns: columns, keys: newDuplicates(budget, tempDir)}
+}
+
+// seen records the row's key and reports whether it was already recorded
+func (k *keyTracker) seen(row map[string]string, line int) bool {
+	if len(k.columns) == 0 {
+		return false
+	}
+
+	parts := make([]string, len(k.columns))
+	for i, col := range k.columns {
+		parts[i] = strings.TrimSpace(row[col])
+	}
+	return k.keys.add(strings.Join(parts, "\x00"), line)
+}
+
+// finish calls report with the line of each duplicate key found after spilling
+func (k *keyTracker) finish(report func(line int)) error {
+	return k.keys.finish(report)
+}
+
+// lineNumber returns the source line number the reader attached to a row
+func lineNumber(row map[string]string) int {
+	line, _ := strconv.Atoi(row[lineColumn])
+	return line
+}
+
+// tally aggregates violations by rule, column and message
+type tally struct {
+	order []string
+	byKey map[string]*types.Violation
+}
+
+func newTally() *tally {
+	return &tally{byKey: make(map[string]*types.Violation)}
+}
+
+func (t *tally) add(rule, column, message string, line int) {
+	key := rule + "\x00" + column + "\x00" + message
+	v, ok := t.byKey[key]
+	if !ok {
+		v = &types.Violation{Rule: rule, Column: column, Message: message}
+		t.byKey[key] = v
+		t.order = append(t.order, key)
+	}
+	v.Count++
+	if line > 0 && len(v.Lines) < maxViolationLines {
+		v.Lines = append(v.Lines, line)
+	}
+}
+
+func (t *tally) list() []types.Violation {
+	list := make([]types.Violation, 0, len(t.order))
+	for _, key := range t.order {
+		list = append(list, *t.byKey[key])
+	}
+	return list
+}
@@ -0,0 +1,159 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"querycraft/pkg/qcparser/detector"
+	"querycraft/pkg/qcparser/internal/reader"
+	"querycraft/pkg/qcparser/internal/util"
+	"querycraft/pkg/qcparser/types"
+	"strings"
+	"time"
+)
+
+// lineColumn is the row key the reader stores source line numbers under
+const lineColumn = "\x00line"
+
+// Validate streams a file and checks every row against a data contract
+func Validate(filePath string, contract *types.SchemaDoc, opts *types.Options) (*types.ValidationReport, error) {
+	start := time.Now()
+	opts = contractOptions(contract, opts)
+
+	detected, err := detector.Detect(filePath, opts)
+	if errors.Is(err, detector.ErrSchemaMismatch) {
+		return schemaReport(err, start), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rowChan, errChan, _ := reader.Read(filePath, relaxNullable(detected), opts)
+	return check(rowChan, errChan, contract, opts, start)
+}
+
+// ValidateReader is like Validate but reads the input from a stream
+func ValidateReader(r io.Reader, contract *types.SchemaDoc, opts *types.Options) (*types.ValidationReport, error) {
+	start := time.Now()
+	opts = contractOptions(contract, opts)
+
+	detected, replay, err := detector.DetectReader(r, opts)
+	if errors.Is(err, detector.ErrSchemaMismatch) {
+		return schemaReport(err, start), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rowChan, errChan, _ := reader.ReadFrom(replay, relaxNullable(detected), opts)
+	return check(rowChan, errChan, contract, opts, start)
+}
+
+// contractOptions pins the contract as schema and asks the reader for line numbers
+func contractOptions(contract *types.SchemaDoc, opts *types.Options) *types.Options {
+	pinned := *opts
+	pinned.Schema = contract
+	pinned.LineColumn = lineColumn
+	return &pinned
+}
+
+// relaxNullable stops the reader from rejecting null rows, so nulls are
+// reported per column by the contract checks instead
+func relaxNullable(detected *types.DetectResponse) *types.DetectResponse {
+	relaxed := *detected
+	relaxed.Columns = make([]types.Column, len(detected.Columns))
+	for i, col := range detected.Columns {
+		col.Nullable = nil
+		relaxed.Columns[i] = col
+	}
+	return &relaxed
+}
+
+// schemaReport reports an input whose columns don't fit the contract
+func schemaReport(err error, start time.Time) *types.ValidationReport {
+	return &types.ValidationReport{
+		Valid:          false,
+		ViolationCount: 1,
+		Violations: []types.Violation{{
+			Rule:    "schema",
+			Message: err.Error(),
+			Count:   1,
+		}},
+		DurationMs: util.DurationMs(start),
+	}
+}
+
+// check consumes the row and error channels and applies the contract rules;
+// it fails when the values of unique columns or keys cannot be spilled
+func check(rowChan <-chan map[string]string, errChan <-chan error, contract *types.SchemaDoc, opts *types.Options, start time.Time) (*types.ValidationReport, error) {
+	violations := newTally()
+
+	// Lines the reader could not parse are violations too
+	done := make(chan struct{})
+	parseErrors := newTally()
+	go func() {
+		defer close(done)
+		for err := range errChan {
+			var lineErr *reader.LineError
+			if errors.As(err, &lineErr) {
+				parseErrors.add("parse", lineErr.Column, lineErr.Kind, lineErr.Line)
+				continue
+			}
+			parseErrors.add("parse", "", err.Error(), 0)
+		}
+	}()
+
+	budget := trackerBudget(contract, opts.MemoryBytes)
+	rules := compileRules(contract, budget, opts.TempDir)
+	keys := newKeyTracker(contract.Key, budget, opts.TempDir)
+
+	var rows int64
+	for row := range rowChan {
+		rows++
+		line := lineNumber(row)
+		for _, rule := range rules {
+			rule.check(row, line, violations)
+		}
+		if keys.seen(row, line) {
+			violations.add("key", "", keyMessage(contract), line)
+		}
+	}
+	<-done
+
+	// Duplicates among spilled values are only known once every row was read
+	var spillErr error
+	for _, rule := range rules {
+		spillErr = errors.Join(spillErr, rule.finish(violations))
+	}
+	spillErr = errors.Join(spillErr, keys.finish(func(line int) {
+		violations.add("key", "", keyMessage(contract), line)
+	}))
+	if spillErr != nil {
+		return nil, fmt.Errorf("checking unique values: %w", spillErr)
+	}
+
+	if contract.MinRows != nil && rows < *contract.MinRows {
+		violations.add("row_count", "", fmt.Sprintf("expected at least %d rows, got %d", *contract.MinRows, rows), 0)
+	}
+	if contract.MaxRows != nil && rows > *contract.MaxRows {
+		violations.add("row_count", "", fmt.Sprintf("expected at most %d rows, got %d", *contract.MaxRows, rows), 0)
+	}
+
+	all := append(parseErrors.list(), violations.list()...)
+	var count int64
+	for _, v := range all {
+		count += v.Count
+	}
+
+	return &types.ValidationReport{
+		Valid:          count == 0,
+		Rows:           rows,
+		ViolationCount: count,
+		Violations:     all,
+		DurationMs:     util.DurationMs(start),
+	}, nil
+}
+
+func keyMessage(contract *types.SchemaDoc) string {
+	return fmt.Sprintf("duplicate key (%s)", strings.Join(contract.Key, ", "))
+}